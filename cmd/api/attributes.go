@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"greenlight.abdulalsh.com/internal/data"
+	"greenlight.abdulalsh.com/internal/validator"
+	"net/http"
+)
+
+// listAttributesHandler returns the attribute schema of a category, with the labels in
+// the negotiated language.
+func (app *application) listAttributesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	lang, v := app.readLanguageHeader(w, r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// any translation will do, we only check that the category exists.
+	_, err = app.catalog(r).CategoryModel.Get(id, app.translationsChain(lang, data.TranslationsAll)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	attributes, err := app.models.Attributes.GetAllForCategory(id, app.languageChain(lang)...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	served := make([]string, len(attributes))
+	for i, a := range attributes {
+		served[i] = a.Language
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"attributes": attributes}, contentLanguage(lang, served...))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createAttributeHandler adds an attribute to the schema of a category.
+func (app *application) createAttributeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Key      string            `json:"key"`
+		Type     string            `json:"type"`
+		Required bool              `json:"required"`
+		Options  []string          `json:"options"`
+		Labels   map[string]string `json:"labels"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	attribute := &data.Attribute{
+		CategoryID: id,
+		Key:        input.Key,
+		Type:       input.Type,
+		Required:   input.Required,
+		Options:    input.Options,
+		Labels:     input.Labels,
+	}
+	v := validator.New()
+	if data.ValidateAttribute(v, attribute); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Attributes.Insert(v, attribute)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateAttribute):
+			v.AddError("key", "the category already has an attribute with this key")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrCategoryDoesntExist):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrInvalidAttributes):
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	attribute.Label, attribute.Language = attribute.Labels[data.SourceLanguage], data.SourceLanguage
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("%s/%d/attributes/%s", categoriesV1, id, attribute.Key))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"attribute": attribute}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateAttributeHandler changes the required flag, the options or the labels of an
+// attribute. The labels given are merged into the existing ones.
+func (app *application) updateAttributeHandler(w http.ResponseWriter, r *http.Request) {
+	attribute, ok := app.readAttribute(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Required *bool             `json:"required"`
+		Options  []string          `json:"options"`
+		Labels   map[string]string `json:"labels"`
+		Version  *int32            `json:"version"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.Version != nil && *input.Version != attribute.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+	if input.Required != nil {
+		attribute.Required = *input.Required
+	}
+	if input.Options != nil {
+		attribute.Options = input.Options
+	}
+	if attribute.Labels == nil {
+		attribute.Labels = make(map[string]string)
+	}
+	for lang, label := range input.Labels {
+		attribute.Labels[lang] = label
+	}
+	v := validator.New()
+	if data.ValidateAttribute(v, attribute); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Attributes.Update(v, attribute)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrInvalidAttributes):
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"attribute": attribute}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteAttributeHandler removes an attribute from the schema of a category, and its
+// values from the items of the category.
+func (app *application) deleteAttributeHandler(w http.ResponseWriter, r *http.Request) {
+	attribute, ok := app.readAttribute(w, r)
+	if !ok {
+		return
+	}
+	err := app.models.Attributes.Delete(attribute.CategoryID, attribute.Key)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Attribute deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readAttribute loads the attribute named by the :id and :key parameters, sending a 404
+// and returning false when there's no such attribute.
+func (app *application) readAttribute(w http.ResponseWriter, r *http.Request) (*data.Attribute, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	key, err := app.readStringParam(r, "key")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	attribute, err := app.models.Attributes.Get(id, *key)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return attribute, true
+}
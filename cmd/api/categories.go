@@ -6,6 +6,8 @@ import (
 	"greenlight.abdulalsh.com/internal/data"
 	"greenlight.abdulalsh.com/internal/validator"
 	"net/http"
+	"slices"
+	"strconv"
 )
 
 // getCategoryHandler
@@ -15,12 +17,37 @@ func (app *application) getCategoryHandler(w http.ResponseWriter, r *http.Reques
 		app.notFoundResponse(w, r)
 		return
 	}
-	lang, v := app.readLanguageHeader(r)
+	lang, v := app.readLanguageHeader(w, r)
+	app.showCategory(w, r, id, lang, v)
+}
+
+// getCategoryBySlugHandler serves the category in the same way as getCategoryHandler, the
+// slug is looked up in the requested language.
+func (app *application) getCategoryBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	lang, v := app.readLanguageHeader(w, r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	id, ok := app.resolveSlug(w, r, categoriesV1, lang, app.catalog(r).CategoryModel.ResolveSlug)
+	if !ok {
+		return
+	}
+	app.showCategory(w, r, id, lang, v)
+}
+
+// showCategory writes the category id along with what the query string asks for.
+func (app *application) showCategory(w http.ResponseWriter, r *http.Request, id int64, lang string, v *validator.Validator) {
+	include := app.readCSV(r.URL.Query(), "include", []string{})
+	data.ValidateCategoryInclude(v, include)
+	translations := app.readString(r.URL.Query(), "translations", "")
+	data.ValidateTranslationsParam(v, translations)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	category, err := app.models.CategoryModel.Get(id, lang)
+	languages := app.translationsChain(lang, translations)
+	category, err := app.catalog(r).CategoryModel.Get(id, languages...)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -31,7 +58,19 @@ func (app *application) getCategoryHandler(w http.ResponseWriter, r *http.Reques
 			return
 		}
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"category": category}, nil)
+	err = app.includeCategoryRelations(r, languages, include, category)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if translations == data.TranslationsAll {
+		err = app.models.CategoryModel.GetAllTranslations(data.Languages.Codes(), category)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"category": category}, contentLanguage(lang, category.Language))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -39,34 +78,181 @@ func (app *application) getCategoryHandler(w http.ResponseWriter, r *http.Reques
 
 // getCategoriesHandler
 func (app *application) getCategoriesHandler(w http.ResponseWriter, r *http.Request) {
-	lang, v := app.readLanguageHeader(r)
+	lang, v := app.readLanguageHeader(w, r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	var input struct {
+		Title        string
+		Include      []string
+		Translations string
+		data.Filters
+	}
+	qs := r.URL.Query()
+	input.Title = app.readString(qs, "title", "")
+	input.Include = app.readCSV(qs, "include", []string{})
+	data.ValidateCategoryInclude(v, input.Include)
+	input.Translations = app.readString(qs, "translations", "")
+	data.ValidateTranslationsParam(v, input.Translations)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "title", "created_at", "position", "-id", "-title", "-created_at", "-position"}
+	data.ValidateFilters(v, input.Filters)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	categories, err := app.models.CategoryModel.GetAll(lang)
+	languages := app.translationsChain(lang, input.Translations)
+	categories, metadata, err := app.catalog(r).CategoryModel.GetAll(languages, input.Title, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.includeCategoryRelations(r, languages, input.Include, categories...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if input.Translations == data.TranslationsAll {
+		err = app.models.CategoryModel.GetAllTranslations(data.Languages.Codes(), categories...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	served := make([]string, len(categories))
+	for i, c := range categories {
+		served[i] = c.Language
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "category": categories}, contentLanguage(lang, served...))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// getCategoryItemsHandler
+func (app *application) getCategoryItemsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	lang, v := app.readLanguageHeader(w, r)
+	var input struct {
+		Name       string
+		Attributes []data.AttributeFilter
+		Tags       []string
+		data.Filters
+	}
+	qs := r.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Attributes = app.readAttributeFilters(qs, v)
+	input.Tags = app.readCSV(qs, "tags", []string{})
+	data.ValidateItemTags(v, input.Tags)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "created_at", "position", "-id", "-name", "-created_at", "-position"}
+	data.ValidateFilters(v, input.Filters)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	languages := app.languageChain(lang)
+	catalog := app.catalog(r)
+	_, err = catalog.CategoryModel.Get(id, languages...)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
-			return
 		default:
 			app.serverErrorResponse(w, r, err)
-			return
 		}
+		return
+	}
+	items, metadata, err := catalog.ItemModel.GetAll(languages, input.Name, id, input.Attributes, input.Tags, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	served := make([]string, len(items))
+	for i, item := range items {
+		served[i] = item.Language
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"category": categories}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "items": items}, contentLanguage(lang, served...))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
+}
 
+// includeCategoryRelations embeds the items and/or the item counts that were asked for
+// with ?include= into each of the categories.
+func (app *application) includeCategoryRelations(r *http.Request, languages []string, include []string, categories ...*data.Category) error {
+	if len(include) == 0 || len(categories) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(categories))
+	for i, c := range categories {
+		ids[i] = c.ID
+	}
+	if slices.Contains(include, data.IncludeItems) {
+		items, err := app.catalog(r).ItemModel.GetAllForCategories(languages, ids)
+		if err != nil {
+			return err
+		}
+		for _, c := range categories {
+			c.Items = items[c.ID]
+			if c.Items == nil {
+				c.Items = []*data.Item{}
+			}
+		}
+	}
+	if slices.Contains(include, data.IncludeItemCount) {
+		counts, err := app.catalog(r).ItemModel.CountForCategories(ids)
+		if err != nil {
+			return err
+		}
+		for _, c := range categories {
+			count := counts[c.ID]
+			c.ItemCount = &count
+		}
+	}
+	return nil
+}
+
+// getCategoryTreeHandler
+func (app *application) getCategoryTreeHandler(w http.ResponseWriter, r *http.Request) {
+	lang, v := app.readLanguageHeader(w, r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	tree, err := app.catalog(r).CategoryModel.GetTree(app.languageChain(lang))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"categories": tree}, contentLanguage(lang))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
 }
 
 // createCategoryHandler
 func (app *application) createCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title string `json:"title"`
-		Image string `json:"image"`
+		Title    string `json:"title"`
+		Image    string `json:"image"`
+		ParentID *int64 `json:"parent_id"`
+		// the translations keyed by language code, which replace title and image
+		Translations map[string]*data.CategoryTranslation `json:"translations"`
+		// a new category is published straight away unless it's given another status
+		Status      string            `json:"status"`
+		PublishAt   data.ScheduleTime `json:"publish_at"`
+		UnpublishAt data.ScheduleTime `json:"unpublish_at"`
 	}
 	err := app.readJSON(w, r, &input)
 	if err != nil {
@@ -75,19 +261,38 @@ func (app *application) createCategoryHandler(w http.ResponseWriter, r *http.Req
 	}
 	category := &data.Category{
 		Title:    input.Title,
-		Language: "en",
+		Language: data.SourceLanguage,
 		Image:    input.Image,
+		ParentID: input.ParentID,
 	}
+	category.Status = data.StatusPublished
+	category.Publishing.Set(input.Status, input.PublishAt, input.UnpublishAt)
 	v := validator.New()
-	data.ValidateCategory(v, category)
+	data.ValidatePublishing(v, category.Publishing)
+	if input.Translations == nil {
+		data.ValidateCategory(v, category)
+	} else {
+		v.Check(input.Title == "" && input.Image == "", "title", "must not be given along with translations")
+		category.Translations = input.Translations
+		data.ValidateCategoryTranslations(v, category)
+	}
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
+	if en, ok := category.Translations[data.SourceLanguage]; ok {
+		category.Title, category.Image = en.Title, en.Image
+	}
 	err = app.models.CategoryModel.Insert(category)
 
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrParentCategoryDoesntExist):
+			v.AddError("parent_id", "referenced parent category does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 	headers := make(http.Header)
@@ -105,14 +310,27 @@ func (app *application) updateCategoryLanugageHandler(w http.ResponseWriter, r *
 		Title    string `json:"title"`
 		Language string `json:"language"`
 		Image    string `json:"image"`
+		// a parent_id of 0 moves the category to the top level
+		ParentID *int64 `json:"parent_id"`
+		// the version the client last read, the update is refused if it's stale
+		Version *int32 `json:"version"`
+		// the publishing state is left as it is when it isn't given, a null time clears it
+		Status      string            `json:"status"`
+		PublishAt   data.ScheduleTime `json:"publish_at"`
+		UnpublishAt data.ScheduleTime `json:"unpublish_at"`
 	}
+	var updateParent = false
 	err := app.readJSON(w, r, &input)
 	if err != nil {
 		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	if app.translatorOnly(r) && (input.Language == data.SourceLanguage || input.ParentID != nil || input.Status != "" || input.PublishAt.Given || input.UnpublishAt.Given) {
+		app.translatorNotPermittedResponse(w, r)
+		return
+	}
 	//get the cat to set default image if image doesn't exists.
-	category, err := app.models.CategoryModel.Get(input.Id, "en")
+	category, err := app.models.CategoryModel.Get(input.Id, data.SourceLanguage)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -123,19 +341,36 @@ func (app *application) updateCategoryLanugageHandler(w http.ResponseWriter, r *
 			return
 		}
 	}
+	if input.Version != nil && *input.Version != category.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
 	category.Language = input.Language
 	category.Title = input.Title
 	if input.Image != "" {
 		category.Image = input.Image
 	}
+	if input.ParentID != nil {
+		updateParent = true
+		category.ParentID = input.ParentID
+		if *input.ParentID == 0 {
+			category.ParentID = nil
+		}
+	}
+	category.Publishing.Set(input.Status, input.PublishAt, input.UnpublishAt)
 
 	v := validator.New()
 	data.ValidateCategory(v, category)
+	data.ValidatePublishing(v, category.Publishing)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.models.CategoryModel.Update(category)
+	if app.translatorOnly(r) {
+		app.proposeTranslation(w, r, &data.Proposal{Type: data.EntityCategory, EntityID: category.ID, Language: category.Language, Text: category.Title, Image: category.Image})
+		return
+	}
+	err = app.models.CategoryModel.Update(category, updateParent)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -143,6 +378,14 @@ func (app *application) updateCategoryLanugageHandler(w http.ResponseWriter, r *
 		case errors.Is(err, data.ErrDublicateCategoryTranslation):
 			v.AddError("category", "duplicate category translation please update category")
 			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrParentCategoryDoesntExist):
+			v.AddError("parent_id", "referenced parent category does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrCategoryCycle):
+			v.AddError("parent_id", "would create a cycle in the category hierarchy")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -166,7 +409,8 @@ func (app *application) deleteCategoryHandler(w http.ResponseWriter, r *http.Req
 		app.notFoundResponse(w, r)
 		return
 	}
-	err = app.models.CategoryModel.Delete(id)
+	v := validator.New()
+	images, err := app.models.CategoryModel.Delete(v, id)
 
 	if err != nil {
 		switch {
@@ -176,13 +420,170 @@ func (app *application) deleteCategoryHandler(w http.ResponseWriter, r *http.Req
 		case errors.Is(err, data.ErrCantDeleteDefaultCategory):
 			app.unableToDeleteDefault(w, r, data.ErrCantDeleteDefaultCategory.Error())
 			return
+		case errors.Is(err, data.ErrInvalidAttributes):
+			app.failedValidationResponse(w, r, v.Errors)
+			return
 		default:
 			app.serverErrorResponse(w, r, err)
 			return
 		}
 	}
+	app.removeOrphanedUploads(images)
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Category deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// mergeCategoryHandler moves the items and sub-categories of a category into the :target
+// category, copies over the translations the target is missing and deletes the category.
+func (app *application) mergeCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	param, err := app.readStringParam(r, "target")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	target, err := strconv.ParseInt(*param, 10, 64)
+	if err != nil || target < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+	v := validator.New()
+	v.Check(id != target, "target", "must not be the category being merged")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	merge, images, err := app.models.CategoryModel.Merge(v, id, target)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrCantDeleteDefaultCategory):
+			app.unableToDeleteDefault(w, r, "the default category can't be merged into another category")
+		case errors.Is(err, data.ErrCategoryCycle):
+			v.AddError("target", "would create a cycle in the category hierarchy")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidAttributes):
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.removeOrphanedUploads(images)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("%s/%d", categoriesV1, target))
+	err = app.writeJSON(w, http.StatusOK, envelope{"merge": merge}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// reorderCategoriesHandler sets the order of sibling categories. The ids must list every
+// child of one parent, or every top level category, in their new order.
+func (app *application) reorderCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		IDs []int64 `json:"ids"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	v := validator.New()
+	validateOrder(v, input.IDs)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	missing, err := app.models.CategoryModel.Reorder(input.IDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("ids", fmt.Sprintf("categories not found: %v", missing))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrIncompleteOrder):
+			v.AddError("ids", "must list every sub category of one parent, or every top level category")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reordered": len(input.IDs)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// reorderCategoryItemsHandler sets the order of the items of a category. The ids must
+// list every item of the category in their new order.
+func (app *application) reorderCategoryItemsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		IDs []int64 `json:"ids"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	v := validator.New()
+	validateOrder(v, input.IDs)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// any translation will do, we only check that the category exists.
+	_, err = app.models.CategoryModel.Get(id, data.Languages.Codes()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	missing, err := app.models.ItemModel.Reorder(id, input.IDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("ids", fmt.Sprintf("items not in the category: %v", missing))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrIncompleteOrder):
+			v.AddError("ids", "must list every item of the category")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reordered": len(input.IDs), "category_id": id}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func validateOrder(v *validator.Validator, ids []int64) {
+	v.Check(len(ids) > 0, "ids", "must contain at least one id")
+	v.Check(len(ids) <= 1000, "ids", "must not contain more than 1000 ids")
+	v.Check(validator.Unique(ids), "ids", "must not contain duplicate values")
+}
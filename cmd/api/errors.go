@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 //log errir method is a generic helper for logging an error message along with method and url
@@ -95,6 +96,48 @@ func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusForbidden, map[string]any{"Error": message})
 }
 
+func (app *application) translatorNotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account can only change the non-English translations"
+	app.errorResponse(w, r, http.StatusForbidden, map[string]any{"Error": message})
+}
+
 func (app *application) unableToDeleteDefault(w http.ResponseWriter, r *http.Request, msg string) {
 	app.errorResponse(w, r, http.StatusUnprocessableEntity, map[string]any{"Error": msg})
 }
+
+func (app *application) fileTooLargeResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the image must not be larger than %d bytes", app.config.uploads.maxSize)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, map[string]any{"Error": message})
+}
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, contentType string) {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	message := fmt.Sprintf("%s is not supported, the image must be a JPEG, PNG or GIF", contentType)
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, map[string]any{"Error": message})
+}
+
+func (app *application) proposalReviewedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the proposal has already been reviewed"
+	app.errorResponse(w, r, http.StatusConflict, map[string]any{"Error": message})
+}
+
+func (app *application) proposalOutdatedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the translation has changed since the proposal was made, it must be proposed again"
+	app.errorResponse(w, r, http.StatusConflict, map[string]any{"Error": message})
+}
+
+func (app *application) proposalDraftResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the proposal is a draft, it must be submitted before it can be reviewed"
+	app.errorResponse(w, r, http.StatusConflict, map[string]any{"Error": message})
+}
+
+func (app *application) proposalSubmittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the proposal has already been submitted"
+	app.errorResponse(w, r, http.StatusConflict, map[string]any{"Error": message})
+}
+
+func (app *application) translationProviderErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+	message := "the machine translation provider failed to translate the texts"
+	app.errorResponse(w, r, http.StatusBadGateway, map[string]any{"Error": message})
+}
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -43,20 +44,72 @@ func (app *application) readStringParam(r *http.Request, param string) (*string,
 	return &p, nil
 }
 
-func (app *application) readLanguageHeader(r *http.Request) (string, *validator.Validator) {
-	lang := r.Header.Get("Accept-Language")
-	if lang == "" {
-		lang = "en" // default to English if no language is specified
-	} else {
-		// Extract the primary language from the header (e.g., "en-US" -> "en")
-		lang = strings.Split(lang, ",")[0]
-		lang = strings.Split(lang, "-")[0]
-	}
+// readLanguageHeader negotiates the language of the response from the Accept-Language
+// header, or from the ?lang= query parameter when one is given.
+func (app *application) readLanguageHeader(w http.ResponseWriter, r *http.Request) (string, *validator.Validator) {
+	// The response depends on the Accept-Language header, so caches must keep a copy
+	// per header value.
+	w.Header().Add("Vary", "Accept-Language")
+
 	v := validator.New()
-	v.Check(validator.PermittedValues(lang, data.AllowedLanguages...), "language", lang+" is not supported")
+	// A ?lang= query parameter takes precedence over the header, which is handy for
+	// links and for clients that can't set headers. It's matched in the same way as a
+	// range of the header, so ?lang=ar-SA gets ar.
+	if tag := r.URL.Query().Get("lang"); tag != "" {
+		lang, ok := matchLanguage(strings.ToLower(tag), data.Languages.Codes())
+		v.Check(ok, "lang", tag+" is not supported")
+		return lang, v
+	}
+	lang, ok := negotiateLanguage(r.Header.Get("Accept-Language"), data.Languages.Codes(), data.SourceLanguage)
+	v.Check(ok, "language", "none of the accepted languages is supported")
 	return lang, v
 }
 
+// languageChain returns the requested language followed by its configured fallbacks,
+// which is the order in which the models look for a translation. Disabled languages are
+// left out of the fallbacks.
+func (app *application) languageChain(lang string) []string {
+	chain := []string{lang}
+	for _, fallback := range app.config.translation.fallbacks[lang] {
+		if !slices.Contains(chain, fallback) && data.Languages.Enabled(fallback) {
+			chain = append(chain, fallback)
+		}
+	}
+	return chain
+}
+
+// translationsChain returns the language chain to read an entity with. When every
+// translation was asked for, the chain is extended with all the other supported languages
+// so that an entity is still found as long as it has any translation at all.
+func (app *application) translationsChain(lang string, translations string) []string {
+	chain := app.languageChain(lang)
+	if translations == data.TranslationsAll {
+		for _, l := range data.Languages.Codes() {
+			if !slices.Contains(chain, l) {
+				chain = append(chain, l)
+			}
+		}
+	}
+	return chain
+}
+
+// contentLanguage returns a header map with the Content-Language header set to the
+// distinct languages that were served, or to the requested language if nothing was.
+func contentLanguage(requested string, served ...string) http.Header {
+	var languages []string
+	for _, lang := range served {
+		if lang != "" && !slices.Contains(languages, lang) {
+			languages = append(languages, lang)
+		}
+	}
+	if len(languages) == 0 {
+		languages = []string{requested}
+	}
+	headers := make(http.Header)
+	headers.Set("Content-Language", strings.Join(languages, ", "))
+	return headers
+}
+
 // we define an envelope type to better represent our data
 type envelope map[string]any
 
@@ -193,6 +246,44 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	}
 }
 
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+	return b
+}
+
+// readAttributeFilters reads the attribute filters of the item listings from the query
+// string: attr.<key>=value matches a value, attr.<key>.min and attr.<key>.max bound a
+// number or a money amount.
+func (app *application) readAttributeFilters(qs url.Values, v *validator.Validator) []data.AttributeFilter {
+	var filters []data.AttributeFilter
+	for param, values := range qs {
+		key, ok := strings.CutPrefix(param, "attr.")
+		if !ok {
+			continue
+		}
+		op := data.FilterEquals
+		if k, ok := strings.CutSuffix(key, "."+data.FilterMin); ok {
+			key, op = k, data.FilterMin
+		} else if k, ok := strings.CutSuffix(key, "."+data.FilterMax); ok {
+			key, op = k, data.FilterMax
+		}
+		for _, value := range values {
+			f := data.AttributeFilter{Key: key, Op: op, Value: value}
+			data.ValidateAttributeFilter(v, f)
+			filters = append(filters, f)
+		}
+	}
+	return filters
+}
+
 func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
 	s := qs.Get(key)
 	if s == "" {
@@ -292,3 +383,21 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 	buf.WriteTo(w)
 
 }
+
+// translatorOnly reports whether the user may only change the non-English translations,
+// that is they got through with catalog:translate rather than catalog:write.
+func (app *application) translatorOnly(r *http.Request) bool {
+	return !app.contextGetPermissions(r).Include(data.CatalogWrite)
+}
+
+// catalog returns the models to read the catalogue with. The editors see every category
+// and item whatever its status, everyone else only sees the published ones.
+func (app *application) catalog(r *http.Request) data.Models {
+	permissions := app.contextGetPermissions(r)
+	for _, code := range catalogEditors {
+		if permissions.Include(code) {
+			return app.models
+		}
+	}
+	return app.models.Published()
+}
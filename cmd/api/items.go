@@ -11,9 +11,17 @@ import (
 // createItemHandler
 func (app *application) createItemHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		CategoryID int64  `json:"category_id"`
-		Name       string `json:"name"`
-		Image      string `json:"image"`
+		CategoryID int64           `json:"category_id"`
+		Name       string          `json:"name"`
+		Image      string          `json:"image"`
+		Attributes data.Attributes `json:"attributes"`
+		Tags       []string        `json:"tags"`
+		// the translations keyed by language code, which replace name and image
+		Translations map[string]*data.ItemTranslation `json:"translations"`
+		// a new item is published straight away unless it's given another status
+		Status      string            `json:"status"`
+		PublishAt   data.ScheduleTime `json:"publish_at"`
+		UnpublishAt data.ScheduleTime `json:"unpublish_at"`
 	}
 	err := app.readJSON(w, r, &input)
 	if err != nil {
@@ -24,17 +32,45 @@ func (app *application) createItemHandler(w http.ResponseWriter, r *http.Request
 		CategoryID: input.CategoryID,
 		Name:       input.Name,
 		Image:      input.Image,
-		Language:   "en",
+		Language:   data.SourceLanguage,
+		Attributes: input.Attributes,
+		Tags:       input.Tags,
 	}
+	if item.Tags == nil {
+		item.Tags = []string{}
+	}
+	item.Status = data.StatusPublished
+	item.Publishing.Set(input.Status, input.PublishAt, input.UnpublishAt)
 	v := validator.New()
-	data.ValidateItem(v, item)
+	data.ValidatePublishing(v, item.Publishing)
+	if input.Translations == nil {
+		data.ValidateItem(v, item)
+	} else {
+		v.Check(input.Name == "" && input.Image == "", "name", "must not be given along with translations")
+		item.Translations = input.Translations
+		data.ValidateItemTranslations(v, item)
+		if en, ok := item.Translations[data.SourceLanguage]; ok && en != nil {
+			item.Name, item.Image = en.Name, en.Image
+		}
+	}
+	err = app.validateItemAttributes(v, item)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 	err = app.models.ItemModel.Insert(item)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrTagDoesntExist):
+			v.AddError("tags", "referenced tag does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -53,12 +89,34 @@ func (app *application) getItemHandler(w http.ResponseWriter, r *http.Request) {
 		app.notFoundResponse(w, r)
 		return
 	}
-	lang, v := app.readLanguageHeader(r)
+	lang, v := app.readLanguageHeader(w, r)
+	app.showItem(w, r, id, lang, v)
+}
+
+// getItemBySlugHandler serves the item in the same way as getItemHandler, the slug is
+// looked up in the requested language.
+func (app *application) getItemBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	lang, v := app.readLanguageHeader(w, r)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	item, err := app.models.ItemModel.Get(id, lang)
+	id, ok := app.resolveSlug(w, r, itemsV1, lang, app.catalog(r).ItemModel.ResolveSlug)
+	if !ok {
+		return
+	}
+	app.showItem(w, r, id, lang, v)
+}
+
+// showItem writes the item id along with its translations when they're asked for.
+func (app *application) showItem(w http.ResponseWriter, r *http.Request, id int64, lang string, v *validator.Validator) {
+	translations := app.readString(r.URL.Query(), "translations", "")
+	data.ValidateTranslationsParam(v, translations)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	item, err := app.catalog(r).ItemModel.Get(id, app.translationsChain(lang, translations)...)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -69,7 +127,14 @@ func (app *application) getItemHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"item": item}, nil)
+	if translations == data.TranslationsAll {
+		err = app.models.ItemModel.GetAllTranslations(data.Languages.Codes(), item)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"item": item}, contentLanguage(lang, item.Language))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -77,23 +142,51 @@ func (app *application) getItemHandler(w http.ResponseWriter, r *http.Request) {
 
 // getItemsHandler
 func (app *application) getItemsHandler(w http.ResponseWriter, r *http.Request) {
-	lang, v := app.readLanguageHeader(r)
+	lang, v := app.readLanguageHeader(w, r)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	items, err := app.models.ItemModel.GetAll(lang)
+	var input struct {
+		Name         string
+		Translations string
+		Attributes   []data.AttributeFilter
+		Tags         []string
+		data.Filters
+	}
+	qs := r.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Attributes = app.readAttributeFilters(qs, v)
+	input.Tags = app.readCSV(qs, "tags", []string{})
+	data.ValidateItemTags(v, input.Tags)
+	input.Translations = app.readString(qs, "translations", "")
+	data.ValidateTranslationsParam(v, input.Translations)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "created_at", "position", "-id", "-name", "-created_at", "-position"}
+	data.ValidateFilters(v, input.Filters)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	items, metadata, err := app.catalog(r).ItemModel.GetAll(app.translationsChain(lang, input.Translations), input.Name, 0, input.Attributes, input.Tags, input.Filters)
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
-			return
-		default:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if input.Translations == data.TranslationsAll {
+		err = app.models.ItemModel.GetAllTranslations(data.Languages.Codes(), items...)
+		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
 		}
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"items": items}, nil)
+	served := make([]string, len(items))
+	for i, item := range items {
+		served[i] = item.Language
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "items": items}, contentLanguage(lang, served...))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -107,6 +200,15 @@ func (app *application) updateItemHandler(w http.ResponseWriter, r *http.Request
 		Name       string `json:"name"`
 		Image      string `json:"image"`
 		Language   string `json:"language"`
+		// the version the client last read, the update is refused if it's stale
+		Version *int32 `json:"version"`
+		// the attributes and the tags replace the existing ones when they're given
+		Attributes data.Attributes `json:"attributes"`
+		Tags       []string        `json:"tags"`
+		// the publishing state is left as it is when it isn't given, a null time clears it
+		Status      string            `json:"status"`
+		PublishAt   data.ScheduleTime `json:"publish_at"`
+		UnpublishAt data.ScheduleTime `json:"unpublish_at"`
 	}
 	var updateCategory = false
 	err := app.readJSON(w, r, &input)
@@ -114,10 +216,13 @@ func (app *application) updateItemHandler(w http.ResponseWriter, r *http.Request
 		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	if app.translatorOnly(r) && (input.Language == data.SourceLanguage || input.CategoryID != 0 || input.Attributes != nil || input.Tags != nil || input.Status != "" || input.PublishAt.Given || input.UnpublishAt.Given) {
+		app.translatorNotPermittedResponse(w, r)
+		return
+	}
 
 	//get the default items
-	item, err := app.models.ItemModel.Get(input.ID, "en")
-	item.Category = ""
+	item, err := app.models.ItemModel.Get(input.ID, data.SourceLanguage)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -128,6 +233,11 @@ func (app *application) updateItemHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 	}
+	item.Category = ""
+	if input.Version != nil && *input.Version != item.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
 
 	if input.CategoryID != 0 {
 		updateCategory = true
@@ -138,14 +248,33 @@ func (app *application) updateItemHandler(w http.ResponseWriter, r *http.Request
 	}
 	item.Name = input.Name
 	item.Language = input.Language
+	if input.Attributes != nil {
+		item.Attributes = input.Attributes
+	}
+	if input.Tags != nil {
+		item.Tags = input.Tags
+	}
+	item.Publishing.Set(input.Status, input.PublishAt, input.UnpublishAt)
 
 	v := validator.New()
 	data.ValidateItem(v, item)
+	data.ValidatePublishing(v, item.Publishing)
+	if updateCategory || input.Attributes != nil {
+		err = app.validateItemAttributes(v, item)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	if app.translatorOnly(r) {
+		app.proposeTranslation(w, r, &data.Proposal{Type: data.EntityItem, EntityID: item.ID, Language: item.Language, Text: item.Name, Image: item.Image})
+		return
+	}
 	err = app.models.ItemModel.Update(item, updateCategory)
 	if err != nil {
 		switch {
@@ -155,6 +284,11 @@ func (app *application) updateItemHandler(w http.ResponseWriter, r *http.Request
 		case errors.Is(err, data.ErrCategoryDoesntExist):
 			v.AddError("item", "referenced category does not exist")
 			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrTagDoesntExist):
+			v.AddError("tags", "referenced tag does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -176,7 +310,7 @@ func (app *application) deleteItemHandler(w http.ResponseWriter, r *http.Request
 		app.notFoundResponse(w, r)
 		return
 	}
-	err = app.models.ItemModel.Delete(id)
+	images, err := app.models.ItemModel.Delete(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -187,34 +321,68 @@ func (app *application) deleteItemHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 	}
+	app.removeOrphanedUploads(images)
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Item deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app *application) getUntranslatedHandler(w http.ResponseWriter, r *http.Request) {
-	lang, err := app.readStringParam(r, "lang")
-	v := validator.New()
-
+// moveItemsHandler moves a list of items into another category in one go.
+func (app *application) moveItemsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ItemIDs    []int64 `json:"item_ids"`
+		CategoryID int64   `json:"category_id"`
+	}
+	err := app.readJSON(w, r, &input)
 	if err != nil {
-		v.AddError("lang", "invalid language")
-		app.failedValidationResponse(w, r, v.Errors)
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	v.Check(validator.PermittedValues(*lang, data.AllowedLanguages...), "lang", *lang+" invalid language")
+	v := validator.New()
+	v.Check(len(input.ItemIDs) > 0, "item_ids", "must contain at least one item")
+	v.Check(len(input.ItemIDs) <= 1000, "item_ids", "must not contain more than 1000 items")
+	v.Check(validator.Unique(input.ItemIDs), "item_ids", "must not contain duplicate values")
+	v.Check(input.CategoryID > 0, "category_id", "must be provided")
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	untranslated, err := app.models.ItemModel.GetAllUntranslated(*lang)
+
+	missing, err := app.models.ItemModel.Move(v, input.ItemIDs, input.CategoryID)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("item_ids", fmt.Sprintf("items not found: %v", missing))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrCategoryDoesntExist):
+			v.AddError("category_id", "referenced category does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidAttributes):
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"items": untranslated}, nil)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"moved": len(input.ItemIDs), "category_id": input.CategoryID}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
+}
 
+// validateItemAttributes checks the attribute values of an item against the attribute
+// schema of its category.
+func (app *application) validateItemAttributes(v *validator.Validator, item *data.Item) error {
+	if item.Attributes == nil {
+		item.Attributes = data.Attributes{}
+	}
+	schema, err := app.models.Attributes.GetAllForCategory(item.CategoryID)
+	if err != nil {
+		return err
+	}
+	data.ValidateAttributeValues(v, schema, item.Attributes)
+	return nil
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"greenlight.abdulalsh.com/internal/data"
+	"greenlight.abdulalsh.com/internal/validator"
+	"net/http"
+	"time"
+)
+
+// listLanguagesHandler
+func (app *application) listLanguagesHandler(w http.ResponseWriter, r *http.Request) {
+	languages, err := app.models.Languages.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"languages": languages}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createLanguageHandler
+func (app *application) createLanguageHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code      string `json:"code"`
+		Name      string `json:"name"`
+		Direction string `json:"direction"`
+		Enabled   *bool  `json:"enabled"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	language := &data.Language{
+		Code:      input.Code,
+		Name:      input.Name,
+		Direction: input.Direction,
+		Enabled:   true,
+	}
+	if language.Direction == "" {
+		language.Direction = data.DirectionLTR
+	}
+	if input.Enabled != nil {
+		language.Enabled = *input.Enabled
+	}
+	v := validator.New()
+	if data.ValidateLanguageRecord(v, language); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Languages.Insert(language)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateLanguage):
+			v.AddError("code", "a language with this code already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.refreshLanguages()
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("%s/%s", languagesV1, language.Code))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"language": language}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateLanguageHandler renames a language, changes its direction or enables/disables it.
+func (app *application) updateLanguageHandler(w http.ResponseWriter, r *http.Request) {
+	code, err := app.readStringParam(r, "code")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	language, err := app.models.Languages.Get(*code)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		Name      *string `json:"name"`
+		Direction *string `json:"direction"`
+		Enabled   *bool   `json:"enabled"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.Name != nil {
+		language.Name = *input.Name
+	}
+	if input.Direction != nil {
+		language.Direction = *input.Direction
+	}
+	if input.Enabled != nil {
+		language.Enabled = *input.Enabled
+	}
+	v := validator.New()
+	if data.ValidateLanguageRecord(v, language); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Languages.Update(language)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.refreshLanguages()
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"language": language}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// refreshLanguages reloads the language registry after a change. A failure is only
+// logged, since the change itself has been saved and the refresher will pick it up.
+func (app *application) refreshLanguages() {
+	err := app.models.Languages.Refresh()
+	if err != nil {
+		app.logger.Error(err.Error())
+	}
+}
+
+// The languageRefresher() method reloads the language registry once every configured
+// interval until the done channel is closed, so that changes made through another
+// instance of the API (or straight in the database) are picked up.
+func (app *application) languageRefresher(done <-chan struct{}) {
+	ticker := time.NewTicker(app.config.languages.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			app.refreshLanguages()
+		}
+	}
+}
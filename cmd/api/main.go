@@ -9,9 +9,12 @@ import (
 	_ "github.com/lib/pq"
 	"greenlight.abdulalsh.com/internal/data"
 	"greenlight.abdulalsh.com/internal/mailer"
+	"greenlight.abdulalsh.com/internal/mt"
+	"greenlight.abdulalsh.com/internal/storage"
 	"greenlight.abdulalsh.com/internal/vsc"
 	"html/template"
 	"log/slog"
+	"net/http"
 	"os"
 	"runtime"
 	"strings"
@@ -54,6 +57,41 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	// translation holds the fallback chain for each language, which is used when a
+	// category or item has no translation in the requested language (e.g. ar -> en).
+	translation struct {
+		fallbacks map[string][]string
+	}
+	// the supported languages are loaded from the languages table at startup and
+	// reloaded every refreshInterval.
+	languages struct {
+		refreshInterval time.Duration
+	}
+	// the sweeper periodically purges expired tokens and accounts that were never
+	// activated.
+	sweeper struct {
+		interval       time.Duration
+		unactivatedAge time.Duration
+		enabled        bool
+	}
+	// uploaded images are kept in dir and served under baseURL. abandonedAge is how
+	// long an unused upload is kept before the sweeper removes it.
+	uploads struct {
+		dir           string
+		baseURL       string
+		maxSize       int64
+		thumbnailSize int
+		abandonedAge  time.Duration
+	}
+	// the translation suggestions come from the machine translation provider, either a
+	// glossary file (dictionary) or a JSON service at url (http).
+	mt struct {
+		provider   string
+		dictionary string
+		url        string
+		apiKey     string
+		timeout    time.Duration
+	}
 }
 
 // this will hold the dependencies for our http handlers, helpers and middleware.
@@ -67,6 +105,8 @@ type application struct {
 	// so we don't need to do anything else to initialize it before we can use it.
 	wg            sync.WaitGroup
 	templateCache map[string]*template.Template
+	storage       storage.Storage
+	mt            mt.Provider
 }
 
 func main() {
@@ -101,6 +141,29 @@ func main() {
 		cfg.cors.trustedOrigins = strings.Fields(val)
 		return nil
 	})
+	cfg.translation.fallbacks = map[string][]string{"ar": {data.SourceLanguage}}
+	flag.Func("translation-fallbacks", `translation fallback chains space separated, e.g. "ar=en fr=ar,en" (default "ar=en")`, func(val string) error {
+		fallbacks, err := parseFallbacks(val)
+		if err != nil {
+			return err
+		}
+		cfg.translation.fallbacks = fallbacks
+		return nil
+	})
+	flag.DurationVar(&cfg.languages.refreshInterval, "languages-refresh-interval", time.Minute, "Interval between reloads of the supported languages")
+	flag.DurationVar(&cfg.sweeper.interval, "sweeper-interval", time.Hour, "Interval between background sweeps")
+	flag.DurationVar(&cfg.sweeper.unactivatedAge, "sweeper-unactivated-age", 7*24*time.Hour, "Remove unactivated users older than this")
+	flag.BoolVar(&cfg.sweeper.enabled, "sweeper-enabled", true, "Enable background sweeper")
+	flag.StringVar(&cfg.uploads.dir, "uploads-dir", "./uploads", "Directory the uploaded images are stored in")
+	flag.StringVar(&cfg.uploads.baseURL, "uploads-base-url", "/uploads", "Base URL the uploaded images are served from")
+	flag.Int64Var(&cfg.uploads.maxSize, "uploads-max-size", 5<<20, "Maximum size in bytes of an uploaded image")
+	flag.IntVar(&cfg.uploads.thumbnailSize, "uploads-thumbnail-size", 256, "Maximum width and height of the generated thumbnails")
+	flag.DurationVar(&cfg.uploads.abandonedAge, "uploads-abandoned-age", 24*time.Hour, "Remove unused uploads older than this")
+	flag.StringVar(&cfg.mt.provider, "mt-provider", mt.ProviderDictionary, "Machine translation provider (dictionary|http)")
+	flag.StringVar(&cfg.mt.dictionary, "mt-dictionary", "", "JSON glossary file of the dictionary machine translation provider")
+	flag.StringVar(&cfg.mt.url, "mt-url", "", "URL of the http machine translation provider")
+	flag.StringVar(&cfg.mt.apiKey, "mt-api-key", "", "API key of the http machine translation provider")
+	flag.DurationVar(&cfg.mt.timeout, "mt-timeout", 10*time.Second, "Timeout of the http machine translation provider")
 
 	// Create a new version boolean flag with the default value of false.
 	displayVersion := flag.Bool("version", false, "Display version and exit")
@@ -152,7 +215,17 @@ func main() {
 
 	cache, err := newTemplateCache()
 	if err != nil {
-		logger.Error("problem initializing template cache", err)
+		logger.Error("problem initializing template cache", "error", err)
+		os.Exit(1)
+	}
+	store, err := storage.NewLocal(cfg.uploads.dir, cfg.uploads.baseURL)
+	if err != nil {
+		logger.Error("problem initializing uploads storage", "error", err)
+		os.Exit(1)
+	}
+	translator, err := mt.New(cfg.mt.provider, cfg.mt.dictionary, cfg.mt.url, cfg.mt.apiKey, &http.Client{Timeout: cfg.mt.timeout})
+	if err != nil {
+		logger.Error("problem initializing machine translation", "error", err)
 		os.Exit(1)
 	}
 	app := &application{
@@ -161,9 +234,22 @@ func main() {
 		models:        data.NewModels(db),
 		mailer:        mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
 		templateCache: cache,
+		storage:       store,
+		mt:            translator,
+	}
+
+	// Load the supported languages from the database before we start serving requests.
+	err = app.models.Languages.Refresh()
+	if err != nil {
+		logger.Error("problem loading languages", "error", err)
+		os.Exit(1)
 	}
+	logger.Info("languages loaded:", "enabled", data.Languages.Codes())
 
 	logger.Info("rate limiter settings:", "rps", cfg.limiter.rps, "burst", cfg.limiter.burst, "Enabled", cfg.limiter.enabled)
+	logger.Info("translation fallbacks:", "fallbacks", cfg.translation.fallbacks)
+	logger.Info("sweeper settings:", "interval", cfg.sweeper.interval, "unactivatedAge", cfg.sweeper.unactivatedAge, "Enabled", cfg.sweeper.enabled)
+	logger.Info("machine translation:", "provider", app.mt.Name())
 
 	err = app.serve()
 	if err != nil {
@@ -173,6 +259,20 @@ func main() {
 	os.Exit(1)
 }
 
+// parseFallbacks parses the value of the translation-fallbacks flag, which is a space
+// separated list of lang=fallback[,fallback...] entries.
+func parseFallbacks(val string) (map[string][]string, error) {
+	fallbacks := make(map[string][]string)
+	for _, entry := range strings.Fields(val) {
+		lang, chain, ok := strings.Cut(entry, "=")
+		if !ok || lang == "" || chain == "" {
+			return nil, fmt.Errorf("invalid translation fallback %q", entry)
+		}
+		fallbacks[lang] = strings.Split(chain, ",")
+	}
+	return fallbacks, nil
+}
+
 // The openDB() function returns a sql.DB connection pool.
 func openDB(cfg config) (*sql.DB, error) {
 	// Use sql.Open() to create an empty connection pool, using the DSN from the config
@@ -222,6 +222,12 @@ func (app *application) requireActivateduser(next http.HandlerFunc) http.Handler
 }
 
 func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	return app.requireAnyPermission([]string{code}, next)
+}
+
+// requireAnyPermission checks that the user has at least one of codes. The permissions
+// are kept in the request context for the handlers that allow less to some of them.
+func (app *application) requireAnyPermission(codes []string, next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		//get user
 		user := app.contextGetUser(r)
@@ -231,11 +237,11 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 			app.serverErrorResponse(w, r, err)
 			return
 		}
-		if !permissions.Include(code) {
+		if !slices.ContainsFunc(codes, permissions.Include) {
 			app.notPermittedResponse(w, r)
 			return
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, app.contextSetPermissions(r, permissions))
 	}
 	return app.requireActivateduser(fn)
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A languageRange is a single entry of an Accept-Language header, such as "ar-SA;q=0.8".
+type languageRange struct {
+	tag string
+	q   float64
+}
+
+// parseAcceptLanguage splits an Accept-Language header (RFC 9110 section 12.5.4) into its
+// language ranges, ordered from the most to the least preferred. Ranges with the same
+// weight keep the order they were sent in, and malformed entries are skipped.
+func parseAcceptLanguage(header string) []languageRange {
+	var ranges []languageRange
+	for _, entry := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(entry, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		valid := true
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(name)) != "q" {
+				continue
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || f < 0 || f > 1 {
+				valid = false
+				break
+			}
+			q = f
+		}
+		if !valid {
+			continue
+		}
+		ranges = append(ranges, languageRange{tag: tag, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+// matchLanguage returns the supported language matched by a language range, using the
+// lookup scheme of BCP 47 (RFC 4647 section 3.4): subtags are removed from the end of the
+// range until what is left is a supported language, so "ar-SA" and "zh-Hant-TW" match
+// "ar" and "zh".
+func matchLanguage(tag string, supported []string) (string, bool) {
+	for tag != "" {
+		for _, lang := range supported {
+			if strings.EqualFold(tag, lang) {
+				return lang, true
+			}
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+		// a single letter subtag such as the "x" of a private use extension can't stand
+		// on its own, so it goes along with the subtag after it.
+		if i := strings.LastIndex(tag, "-"); i >= 0 && len(tag)-i == 2 {
+			tag = tag[:i]
+		}
+	}
+	return "", false
+}
+
+// negotiateLanguage picks the supported language that best satisfies the Accept-Language
+// header. Ranges are tried in order of preference, a "*" matches the fallback (or
+// failing that, any supported) language, and a q-value of 0 rules a language out, or
+// with "*;q=0" every language the header doesn't ask for by name. When nothing in the
+// header is acceptable the fallback language is served instead, since RFC 9110 lets us
+// disregard the header rather than respond with a 406, or any other supported language
+// when the fallback has itself been ruled out. The boolean result is false only when
+// every supported language has been ruled out.
+func negotiateLanguage(header string, supported []string, fallback string) (string, bool) {
+	ranges := parseAcceptLanguage(header)
+
+	excluded := make(map[string]bool)
+	named := make(map[string]bool)
+	othersExcluded := false
+	for _, r := range ranges {
+		switch {
+		case r.tag == "*":
+			othersExcluded = othersExcluded || r.q == 0
+		case r.q == 0:
+			if lang, ok := matchLanguage(r.tag, supported); ok && lang == r.tag {
+				excluded[lang] = true
+			}
+		default:
+			if lang, ok := matchLanguage(r.tag, supported); ok {
+				named[lang] = true
+			}
+		}
+	}
+
+	acceptable := func(lang string) bool {
+		return !excluded[lang] && (named[lang] || !othersExcluded)
+	}
+
+	for _, r := range ranges {
+		if r.q == 0 {
+			continue
+		}
+		if r.tag == "*" {
+			if acceptable(fallback) {
+				return fallback, true
+			}
+			for _, lang := range supported {
+				if acceptable(lang) {
+					return lang, true
+				}
+			}
+			continue
+		}
+		if lang, ok := matchLanguage(r.tag, supported); ok && acceptable(lang) {
+			return lang, true
+		}
+	}
+
+	if acceptable(fallback) {
+		return fallback, true
+	}
+	for _, lang := range supported {
+		if acceptable(lang) {
+			return lang, true
+		}
+	}
+	return "", false
+}
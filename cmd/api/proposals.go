@@ -0,0 +1,331 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"greenlight.abdulalsh.com/internal/data"
+	"greenlight.abdulalsh.com/internal/validator"
+	"net/http"
+)
+
+// proposeTranslation saves a translation change made by a translator as a pending
+// proposal instead of applying it, and answers with 202 Accepted.
+func (app *application) proposeTranslation(w http.ResponseWriter, r *http.Request, proposal *data.Proposal) {
+	proposal.ProposedBy = &app.contextGetUser(r).ID
+	err := app.models.Proposals.Insert(proposal)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("%s/proposals/%d", translationsV1, proposal.ID))
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"proposal": proposal}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listProposalsHandler lists the proposals, the pending ones by default. The translators
+// only see their own proposals and the drafts.
+func (app *application) listProposalsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status   string
+		Language string
+		Type     string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Status = app.readString(qs, "status", data.ProposalPending)
+	data.ValidateProposalStatus(v, input.Status)
+	input.Language = app.readString(qs, "language", "")
+	if input.Language != "" {
+		data.ValidateLanguage(v, input.Language)
+	}
+	input.Type = app.readString(qs, "type", "")
+	data.ValidateEntityType(v, input.Type)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+	data.ValidateFilters(v, input.Filters)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var proposedBy int64
+	if app.translatorOnly(r) {
+		proposedBy = app.contextGetUser(r).ID
+	}
+	proposals, metadata, err := app.models.Proposals.GetAll(input.Status, input.Language, input.Type, proposedBy, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "proposals": proposals}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getProposalHandler returns a proposal with the current translation and the diff from
+// it to the proposed text.
+func (app *application) getProposalHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	proposal, err := app.models.Proposals.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !app.canSeeProposal(r, proposal) {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"proposal": proposal}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// canSeeProposal reports whether the user may see the proposal, the translators only
+// see their own proposals and the drafts.
+func (app *application) canSeeProposal(r *http.Request, proposal *data.Proposal) bool {
+	if !app.translatorOnly(r) || proposal.Status == data.ProposalDraft {
+		return true
+	}
+	return proposal.ProposedBy != nil && *proposal.ProposedBy == app.contextGetUser(r).ID
+}
+
+// submitProposalHandler sends a draft for review, with the text and image edited by the
+// translator when they're given.
+func (app *application) submitProposalHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Text  *string `json:"text"`
+		Image *string `json:"image"`
+	}
+	if r.ContentLength != 0 {
+		err = app.readJSON(w, r, &input)
+		if err != nil {
+			app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	proposal, err := app.models.Proposals.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !app.canSeeProposal(r, proposal) {
+		app.notFoundResponse(w, r)
+		return
+	}
+	if proposal.Status != data.ProposalDraft {
+		app.proposalSubmittedResponse(w, r)
+		return
+	}
+	if input.Text != nil {
+		proposal.Text = *input.Text
+	}
+	if input.Image != nil {
+		proposal.Image = *input.Image
+	}
+	v := validator.New()
+	data.ValidateTranslationUnit(v, proposal.Language, &data.TranslationUnit{Type: proposal.Type, Target: &proposal.Text, SourceImage: proposal.Image})
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	proposal, err = app.models.Proposals.Submit(id, app.contextGetUser(r).ID, proposal.Text, proposal.Image)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrProposalSubmitted):
+			app.proposalSubmittedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"proposal": proposal}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// approveProposalHandler applies a pending proposal to its translation.
+func (app *application) approveProposalHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	proposal, err := app.models.Proposals.Approve(id, app.contextGetUser(r).ID)
+	app.reviewResponse(w, r, proposal, err)
+}
+
+// rejectProposalHandler turns down a pending proposal, optionally with a note telling
+// the translator why.
+func (app *application) rejectProposalHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		err = app.readJSON(w, r, &input)
+		if err != nil {
+			app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	v := validator.New()
+	v.Check(len(input.Note) <= 500, "note", "must not be more than 500 bytes long")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	proposal, err := app.models.Proposals.Reject(id, app.contextGetUser(r).ID, input.Note)
+	app.reviewResponse(w, r, proposal, err)
+}
+
+func (app *application) reviewResponse(w http.ResponseWriter, r *http.Request, proposal *data.Proposal, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrProposalReviewed):
+			app.proposalReviewedResponse(w, r)
+		case errors.Is(err, data.ErrProposalDraft):
+			app.proposalDraftResponse(w, r)
+		case errors.Is(err, data.ErrProposalOutdated):
+			app.proposalOutdatedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"proposal": proposal}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// suggestTranslationsHandler runs the categories and items missing a translation in a
+// language through the machine translation provider, and saves the results as draft
+// proposals. The suggestions are never applied on their own, a translator has to submit
+// each of them and a reviewer to approve it.
+func (app *application) suggestTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Language string `json:"language"`
+		Type     string `json:"type"`
+		// the number of categories and items to suggest translations for
+		Limit *int `json:"limit"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := 20
+	if input.Limit != nil {
+		limit = *input.Limit
+	}
+	v := validator.New()
+	v.Check(input.Language != "", "language", "must be provided")
+	v.Check(data.Languages.Enabled(input.Language), "language", input.Language+" is not supported")
+	v.Check(input.Language != data.SourceLanguage, "language", "must not be the source language")
+	data.ValidateEntityType(v, input.Type)
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 100, "limit", "must be a maximum of 100")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	filters := data.Filters{Page: 1, PageSize: limit, Sort: "type", SortSafelist: []string{"type"}}
+	untranslated, _, err := app.models.Translations.Unproposed(input.Language, input.Type, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	var sources []*data.Untranslated
+	for _, u := range untranslated {
+		if u.SourceText != "" {
+			sources = append(sources, u)
+		}
+	}
+	texts := make([]string, len(sources))
+	for i, u := range sources {
+		texts[i] = u.SourceText
+	}
+
+	proposals := []*data.Proposal{}
+	if len(texts) > 0 {
+		translations, err := app.mt.Translate(r.Context(), data.SourceLanguage, input.Language, texts)
+		if err == nil && len(translations) != len(texts) {
+			err = fmt.Errorf("%s provider returned %d translations for %d texts", app.mt.Name(), len(translations), len(texts))
+		}
+		if err != nil {
+			app.translationProviderErrorResponse(w, r, err)
+			return
+		}
+		user := app.contextGetUser(r)
+		for i, u := range sources {
+			if translations[i] == "" {
+				continue
+			}
+			proposals = append(proposals, &data.Proposal{
+				Type:       u.Type,
+				EntityID:   u.ID,
+				Language:   input.Language,
+				Text:       translations[i],
+				Image:      u.SourceImage,
+				Status:     data.ProposalDraft,
+				ProposedBy: &user.ID,
+				Provider:   app.mt.Name(),
+			})
+		}
+		err = app.models.Proposals.Insert(proposals...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	result := envelope{
+		"provider":    app.mt.Name(),
+		"suggested":   len(proposals),
+		"unsuggested": len(untranslated) - len(proposals),
+		"proposals":   proposals,
+	}
+	err = app.writeJSON(w, http.StatusCreated, envelope{"suggestions": result}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
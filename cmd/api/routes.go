@@ -4,15 +4,24 @@ import (
 	"expvar"
 	"github.com/julienschmidt/httprouter"
 	"greenlight.abdulalsh.com/internal/data"
+	"greenlight.abdulalsh.com/internal/storage"
 	"greenlight.abdulalsh.com/ui"
 	"net/http"
+	"strings"
 )
 
 const (
-	categoriesV1 = "/v1/categories"
-	itemsV1      = "/v1/items"
+	categoriesV1   = "/v1/categories"
+	itemsV1        = "/v1/items"
+	languagesV1    = "/v1/languages"
+	translationsV1 = "/v1/translations"
+	tagsV1         = "/v1/tags"
 )
 
+// catalogEditors can upsert translations, the handlers hold back the rest of the changes
+// from the users who only have catalog:translate.
+var catalogEditors = []string{data.CatalogWrite, data.CatalogTranslate}
+
 func (app *application) routes() http.Handler {
 	//init a new httprouter
 	// now we use mux.handle function to register the file server as handler for all url paths that start with /static/
@@ -42,19 +51,79 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 
 	//categories
-	router.HandlerFunc(http.MethodGet, categoriesV1+"/:id", app.getCategoryHandler)
-	router.HandlerFunc(http.MethodGet, categoriesV1, app.getCategoriesHandler)
-	router.HandlerFunc(http.MethodPost, categoriesV1, app.createCategoryHandler)
-	router.HandlerFunc(http.MethodPut, categoriesV1, app.updateCategoryLanugageHandler)
-	router.HandlerFunc(http.MethodDelete, categoriesV1+"/:id", app.deleteCategoryHandler)
+	router.HandlerFunc(http.MethodGet, categoriesV1+"/:id", app.requirePermission(data.CatalogRead, app.getCategoryHandler))
+	router.HandlerFunc(http.MethodGet, categoriesV1+"/:id/items", app.requirePermission(data.CatalogRead, app.getCategoryItemsHandler))
+	router.HandlerFunc(http.MethodGet, categoriesV1, app.requirePermission(data.CatalogRead, app.getCategoriesHandler))
+	router.HandlerFunc(http.MethodPost, categoriesV1, app.requirePermission(data.CatalogWrite, app.createCategoryHandler))
+	router.HandlerFunc(http.MethodPut, categoriesV1, app.requireAnyPermission(catalogEditors, app.updateCategoryLanugageHandler))
+	router.HandlerFunc(http.MethodDelete, categoriesV1+"/:id", app.requirePermission(data.CatalogWrite, app.deleteCategoryHandler))
+	router.HandlerFunc(http.MethodDelete, categoriesV1+"/:id/translations/:lang", app.requirePermission(data.CatalogWrite, app.deleteCategoryTranslationHandler))
+	router.HandlerFunc(http.MethodPut, categoriesV1+"/:id/items/order", app.requirePermission(data.CatalogWrite, app.reorderCategoryItemsHandler))
+	router.HandlerFunc(http.MethodPost, categoriesV1+"/:id/merge-into/:target", app.requirePermission(data.CatalogWrite, app.mergeCategoryHandler))
+	router.HandlerFunc(http.MethodGet, categoriesV1+"/:id/attributes", app.requirePermission(data.CatalogRead, app.listAttributesHandler))
+	router.HandlerFunc(http.MethodPost, categoriesV1+"/:id/attributes", app.requirePermission(data.CatalogWrite, app.createAttributeHandler))
+	router.HandlerFunc(http.MethodPatch, categoriesV1+"/:id/attributes/:key", app.requirePermission(data.CatalogWrite, app.updateAttributeHandler))
+	router.HandlerFunc(http.MethodDelete, categoriesV1+"/:id/attributes/:key", app.requirePermission(data.CatalogWrite, app.deleteAttributeHandler))
 
 	//Items
-	router.HandlerFunc(http.MethodGet, itemsV1+"/:id", app.getItemHandler)
-	router.HandlerFunc(http.MethodGet, itemsV1, app.getItemsHandler)
-	router.HandlerFunc(http.MethodPost, itemsV1, app.createItemHandler)
-	router.HandlerFunc(http.MethodPut, itemsV1, app.updateItemHandler)
-	router.HandlerFunc(http.MethodDelete, itemsV1+"/:id", app.deleteItemHandler)
-	router.HandlerFunc(http.MethodGet, itemsV1+"untranslated/:lang", app.getUntranslatedHandler)
-
-	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router))))
+	router.HandlerFunc(http.MethodGet, itemsV1+"/:id", app.requirePermission(data.CatalogRead, app.getItemHandler))
+	router.HandlerFunc(http.MethodGet, itemsV1, app.requirePermission(data.CatalogRead, app.getItemsHandler))
+	router.HandlerFunc(http.MethodPost, itemsV1, app.requirePermission(data.CatalogWrite, app.createItemHandler))
+	router.HandlerFunc(http.MethodPut, itemsV1, app.requireAnyPermission(catalogEditors, app.updateItemHandler))
+	router.HandlerFunc(http.MethodDelete, itemsV1+"/:id", app.requirePermission(data.CatalogWrite, app.deleteItemHandler))
+	router.HandlerFunc(http.MethodDelete, itemsV1+"/:id/translations/:lang", app.requirePermission(data.CatalogWrite, app.deleteItemTranslationHandler))
+	router.HandlerFunc(http.MethodPost, itemsV1+"/move", app.requirePermission(data.CatalogWrite, app.moveItemsHandler))
+
+	//tags
+	router.HandlerFunc(http.MethodGet, tagsV1, app.requirePermission(data.CatalogRead, app.listTagsHandler))
+	router.HandlerFunc(http.MethodGet, tagsV1+"/:id", app.requirePermission(data.CatalogRead, app.getTagHandler))
+	router.HandlerFunc(http.MethodPost, tagsV1, app.requirePermission(data.CatalogWrite, app.createTagHandler))
+	router.HandlerFunc(http.MethodPatch, tagsV1+"/:id", app.requirePermission(data.CatalogWrite, app.updateTagHandler))
+	router.HandlerFunc(http.MethodDelete, tagsV1+"/:id", app.requirePermission(data.CatalogWrite, app.deleteTagHandler))
+
+	//languages
+	router.HandlerFunc(http.MethodGet, languagesV1, app.listLanguagesHandler)
+	router.HandlerFunc(http.MethodPost, languagesV1, app.requirePermission(data.LanguagesWrite, app.createLanguageHandler))
+	router.HandlerFunc(http.MethodPatch, languagesV1+"/:code", app.requirePermission(data.LanguagesWrite, app.updateLanguageHandler))
+
+	//uploads
+	router.HandlerFunc(http.MethodPost, "/v1/uploads", app.requireAnyPermission(catalogEditors, app.createUploadHandler))
+	if local, ok := app.storage.(*storage.Local); ok && strings.HasPrefix(app.config.uploads.baseURL, "/") {
+		router.Handler(http.MethodGet, strings.TrimSuffix(app.config.uploads.baseURL, "/")+"/*filepath", local.Handler())
+	}
+
+	//translations
+	router.HandlerFunc(http.MethodGet, translationsV1+"/coverage", app.requirePermission(data.CatalogRead, app.translationCoverageHandler))
+	router.HandlerFunc(http.MethodGet, translationsV1+"/untranslated/:lang", app.requirePermission(data.CatalogRead, app.listUntranslatedHandler))
+	router.HandlerFunc(http.MethodGet, translationsV1+"/export", app.requirePermission(data.CatalogRead, app.exportTranslationsHandler))
+	router.HandlerFunc(http.MethodPost, translationsV1+"/import", app.requireAnyPermission(catalogEditors, app.importTranslationsHandler))
+	router.HandlerFunc(http.MethodPost, translationsV1+"/suggest", app.requireAnyPermission(catalogEditors, app.suggestTranslationsHandler))
+	router.HandlerFunc(http.MethodGet, translationsV1+"/proposals", app.requireAnyPermission(catalogEditors, app.listProposalsHandler))
+	router.HandlerFunc(http.MethodGet, translationsV1+"/proposals/:id", app.requireAnyPermission(catalogEditors, app.getProposalHandler))
+	router.HandlerFunc(http.MethodPost, translationsV1+"/proposals/:id/submit", app.requireAnyPermission(catalogEditors, app.submitProposalHandler))
+	router.HandlerFunc(http.MethodPost, translationsV1+"/proposals/:id/approve", app.requirePermission(data.CatalogWrite, app.approveProposalHandler))
+	router.HandlerFunc(http.MethodPost, translationsV1+"/proposals/:id/reject", app.requirePermission(data.CatalogWrite, app.rejectProposalHandler))
+
+	//httprouter doesn't allow a static path segment in the same position as a wildcard, so
+	//routes like /v1/categories/tree (next to /v1/categories/:id) go on their own router which is checked first
+	static := httprouter.New()
+	static.HandlerFunc(http.MethodGet, categoriesV1+"/tree", app.requirePermission(data.CatalogRead, app.getCategoryTreeHandler))
+	static.HandlerFunc(http.MethodPut, categoriesV1+"/order", app.requirePermission(data.CatalogWrite, app.reorderCategoriesHandler))
+	static.HandlerFunc(http.MethodGet, categoriesV1+"/by-slug/:slug", app.requirePermission(data.CatalogRead, app.getCategoryBySlugHandler))
+	static.HandlerFunc(http.MethodGet, itemsV1+"/untranslated/:lang", app.requirePermission(data.CatalogRead, app.listUntranslatedItemsHandler))
+	static.HandlerFunc(http.MethodGet, itemsV1+"/by-slug/:slug", app.requirePermission(data.CatalogRead, app.getItemBySlugHandler))
+
+	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(staticFirst(static, router)))))
+}
+
+// staticFirst serves the request from the static router when it has a matching route,
+// and falls through to the main router otherwise.
+func staticFirst(static, router *httprouter.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handle, params, _ := static.Lookup(r.Method, r.URL.Path); handle != nil {
+			handle(w, r, params)
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
 }
@@ -26,6 +26,21 @@ func (app *application) serve() error {
 	// by the graceful Shutdown() function.
 	shutdownError := make(chan error)
 
+	// Start the sweeper and the language refresher in the background. Closing the
+	// stopJobs channel during the shutdown lets them return, so that app.wg.Wait()
+	// below doesn't block forever.
+	stopJobs := make(chan struct{})
+	if app.config.sweeper.enabled && app.config.sweeper.interval > 0 {
+		app.background(func() {
+			app.sweeper(stopJobs)
+		})
+	}
+	if app.config.languages.refreshInterval > 0 {
+		app.background(func() {
+			app.languageRefresher(stopJobs)
+		})
+	}
+
 	//start a background go routine that will capture the signal for stoping for graceful shutdown
 	go func() {
 		//create a quit channel which carries os.Signal values
@@ -60,6 +75,7 @@ func (app *application) serve() error {
 		// Log a message to say that we're waiting for any background goroutines to
 		// complete their tasks.
 		app.logger.Info("completing background tasks", "addr", srv.Addr)
+		close(stopJobs)
 
 		// Call Wait() to block until our WaitGroup counter is zero --- essentially
 		// blocking until the background goroutines have finished. Then we return nil on
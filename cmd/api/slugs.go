@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"greenlight.abdulalsh.com/internal/data"
+	"net/http"
+	"net/url"
+)
+
+// resolveSlug looks up the :slug parameter in the requested language. An old slug of a
+// renamed entity is answered with a redirect to its current one, ok is false whenever a
+// response has already been sent.
+func (app *application) resolveSlug(w http.ResponseWriter, r *http.Request, base, lang string, resolve func(lang, slug string) (int64, string, error)) (int64, bool) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+	id, current, err := resolve(lang, slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return 0, false
+	}
+	if current != slug {
+		location := base + "/by-slug/" + url.PathEscape(current)
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		headers := make(http.Header)
+		headers.Set("Location", location)
+		err = app.writeJSON(w, http.StatusMovedPermanently, envelope{"slug": current, "location": location}, headers)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// A sweepTask is a single maintenance job run by the sweeper. The run function returns
+// the number of rows it removed or changed so that we can log what the sweeper actually
+// did.
+type sweepTask struct {
+	name string
+	run  func() (int64, error)
+}
+
+// sweepTasks returns the maintenance jobs that are run on every sweep.
+func (app *application) sweepTasks() []sweepTask {
+	return []sweepTask{
+		{
+			name: "expired tokens",
+			run:  app.models.Token.DeleteExpired,
+		},
+		{
+			name: "unactivated users",
+			run: func() (int64, error) {
+				return app.models.Users.DeleteUnactivated(app.config.sweeper.unactivatedAge)
+			},
+		},
+		{
+			name: "abandoned uploads",
+			run:  app.removeAbandonedUploads,
+		},
+		// the readers see a schedule at the right time anyway, these save the statuses.
+		{
+			name: "scheduled categories",
+			run:  app.models.CategoryModel.PublishDue,
+		},
+		{
+			name: "scheduled items",
+			run:  app.models.ItemModel.PublishDue,
+		},
+	}
+}
+
+// The sweeper() method runs all the sweep tasks straight away and then once every
+// configured interval, until the done channel is closed. It is started through the
+// background() helper so that it is tracked by app.wg, which means that serve() will
+// wait for an in-flight sweep to complete during a graceful shutdown.
+func (app *application) sweeper(done <-chan struct{}) {
+	ticker := time.NewTicker(app.config.sweeper.interval)
+	defer ticker.Stop()
+
+	for {
+		app.sweep()
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep runs each task once, logging how many rows were removed or changed. A failing or
+// panicking task is logged and doesn't stop the remaining tasks from running.
+func (app *application) sweep() {
+	for _, task := range app.sweepTasks() {
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					app.logger.Error(fmt.Sprintf("%v", err), "task", task.name)
+				}
+			}()
+
+			rows, err := task.run()
+			if err != nil {
+				app.logger.Error(err.Error(), "task", task.name)
+				return
+			}
+			if rows > 0 {
+				app.logger.Info("sweeper processed rows", "task", task.name, "rows", rows)
+			}
+		}()
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"greenlight.abdulalsh.com/internal/data"
+	"greenlight.abdulalsh.com/internal/validator"
+	"net/http"
+)
+
+// listTagsHandler returns every tag with its name in the negotiated language.
+func (app *application) listTagsHandler(w http.ResponseWriter, r *http.Request) {
+	lang, v := app.readLanguageHeader(w, r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	tags, err := app.models.Tags.GetAll(app.languageChain(lang))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	served := make([]string, len(tags))
+	for i, tag := range tags {
+		served[i] = tag.Language
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"tags": tags}, contentLanguage(lang, served...))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getTagHandler
+func (app *application) getTagHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	lang, v := app.readLanguageHeader(w, r)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	tag, err := app.models.Tags.Get(id, app.languageChain(lang)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"tag": tag}, contentLanguage(lang, tag.Language))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createTagHandler creates a tag with its English name, the other languages are added
+// with updateTagHandler.
+func (app *application) createTagHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	tag := &data.Tag{
+		Slug:     input.Slug,
+		Name:     input.Name,
+		Language: data.SourceLanguage,
+	}
+	v := validator.New()
+	if data.ValidateTag(v, tag); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Tags.Insert(tag)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTag):
+			v.AddError("slug", "a tag with this slug already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("%s/%d", tagsV1, tag.ID))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"tag": tag}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateTagHandler upserts the name of a tag in a language and can change its slug.
+func (app *application) updateTagHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Slug     *string `json:"slug"`
+		Name     string  `json:"name"`
+		Language string  `json:"language"`
+		// the version the client last read, the update is refused if it's stale
+		Version *int32 `json:"version"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	tag, err := app.models.Tags.Get(id, data.Languages.Codes()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if input.Version != nil && *input.Version != tag.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+	if input.Slug != nil {
+		tag.Slug = *input.Slug
+	}
+	tag.Name = input.Name
+	tag.Language = input.Language
+	tag.Fallback = false
+
+	v := validator.New()
+	if data.ValidateTag(v, tag); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Tags.Update(tag)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTag):
+			v.AddError("slug", "a tag with this slug already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"tag": tag}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteTagHandler deletes a tag and takes it off the items.
+func (app *application) deleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Tags.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Tag deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"greenlight.abdulalsh.com/internal/data"
+	"greenlight.abdulalsh.com/internal/exchange"
+	"greenlight.abdulalsh.com/internal/validator"
+	"net/http"
+	"strings"
+)
+
+// translationCoverageHandler reports, for every enabled language, how many categories
+// and items are translated.
+func (app *application) translationCoverageHandler(w http.ResponseWriter, r *http.Request) {
+	coverage, err := app.models.Translations.Coverage()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"coverage": coverage}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listUntranslatedHandler lists the categories and items missing a translation in the
+// :lang language, optionally restricted to one kind with ?type=category|item.
+func (app *application) listUntranslatedHandler(w http.ResponseWriter, r *http.Request) {
+	app.untranslatedResponse(w, r, r.URL.Query().Get("type"))
+}
+
+// listUntranslatedItemsHandler lists the items missing a translation in the :lang language.
+func (app *application) listUntranslatedItemsHandler(w http.ResponseWriter, r *http.Request) {
+	app.untranslatedResponse(w, r, data.EntityItem)
+}
+
+func (app *application) untranslatedResponse(w http.ResponseWriter, r *http.Request, entityType string) {
+	lang, err := app.readStringParam(r, "lang")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "type")
+	input.Filters.SortSafelist = []string{"type", "id", "created_at", "source_text", "-type", "-id", "-created_at", "-source_text"}
+	v.Check(data.Languages.Enabled(*lang), "lang", *lang+" is not supported")
+	data.ValidateEntityType(v, entityType)
+	data.ValidateFilters(v, input.Filters)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	untranslated, metadata, err := app.models.Translations.Untranslated(*lang, entityType, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "untranslated": untranslated}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteCategoryTranslationHandler removes the :lang translation of a category.
+func (app *application) deleteCategoryTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	app.deleteTranslation(w, r, app.models.CategoryModel.DeleteTranslation)
+}
+
+// deleteItemTranslationHandler removes the :lang translation of an item.
+func (app *application) deleteItemTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	app.deleteTranslation(w, r, app.models.ItemModel.DeleteTranslation)
+}
+
+func (app *application) deleteTranslation(w http.ResponseWriter, r *http.Request, del func(id int64, lang string) (string, error)) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	lang, err := app.readStringParam(r, "lang")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	image, err := del(id, *lang)
+	if err != nil {
+		v := validator.New()
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrSourceTranslation):
+			v.AddError("lang", "the "+data.SourceLanguage+" translation is the source of the others and can't be deleted")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrLastTranslation):
+			v.AddError("lang", "the last translation can't be deleted")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.removeOrphanedUploads([]string{image})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Translation deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// unitID is the identifier of a translation unit in the exported files, e.g. "item/42".
+func unitID(entityType string, id int64) string {
+	return fmt.Sprintf("%s/%d", entityType, id)
+}
+
+// readExchangeParams reads the lang and format query parameters of the export and import
+// endpoints. The source language can't be exported or imported since it's what the
+// translations are made from.
+func (app *application) readExchangeParams(r *http.Request, v *validator.Validator) (string, string) {
+	qs := r.URL.Query()
+	lang := app.readString(qs, "lang", "")
+	format := app.readString(qs, "format", exchange.FormatXLIFF)
+	v.Check(lang != "", "lang", "must be provided")
+	v.Check(lang == "" || data.Languages.Enabled(lang), "lang", lang+" is not supported")
+	v.Check(lang != data.SourceLanguage, "lang", "must not be the source language")
+	v.Check(validator.PermittedValues(format, exchange.FormatXLIFF, exchange.FormatPO), "format", "must be xliff or po")
+	return lang, format
+}
+
+// exportTranslationsHandler sends the source text of every category and item along with
+// its existing lang translation as an XLIFF or PO file.
+func (app *application) exportTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	lang, format := app.readExchangeParams(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	units, err := app.models.Translations.Units(lang)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	doc := exchange.Document{SourceLanguage: data.SourceLanguage, TargetLanguage: lang}
+	for _, u := range units {
+		unit := exchange.Unit{ID: unitID(u.Type, u.ID), Source: u.Source}
+		if u.Target != nil {
+			unit.Target = *u.Target
+		}
+		doc.Units = append(doc.Units, unit)
+	}
+
+	var buf bytes.Buffer
+	err = exchange.Write(&buf, format, doc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", exchange.ContentType(format)+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="catalog-%s%s"`, lang, exchange.Extension(format)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// The statuses of the units of an imported file.
+const (
+	importAdded         = "added"
+	importChanged       = "changed"
+	importUnchanged     = "unchanged"
+	importUntranslated  = "untranslated"
+	importSourceChanged = "source_changed"
+	importUnknown       = "unknown"
+	importRejected      = "rejected" // the target fails the validation of the updates
+)
+
+// importChange is a line of the diff returned by the import endpoint.
+type importChange struct {
+	Unit   string  `json:"unit"`
+	Source string  `json:"source"`
+	Old    *string `json:"old,omitempty"`
+	New    string  `json:"new"`
+	Status string  `json:"status"`
+	// the validation errors of a rejected unit
+	Errors map[string]any `json:"errors,omitempty"`
+}
+
+// importTranslationsHandler reads an XLIFF or PO file from the request body and works
+// out which lang translations it adds or changes. Unless ?dry_run=false is given the
+// diff is only returned, so it can be reviewed before the import is committed. Units
+// whose source text has changed since the export are left out, as their translation
+// may no longer be accurate, and so are the ones the updates would refuse.
+func (app *application) importTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	lang, format := app.readExchangeParams(r, v)
+	dryRun := app.readBool(r.URL.Query(), "dry_run", true, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+	doc, err := exchange.Read(r.Body, format)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	// tools often write the target language with a region, e.g. ar_SA.
+	target := strings.ToLower(strings.ReplaceAll(doc.TargetLanguage, "_", "-"))
+	if _, ok := matchLanguage(target, []string{lang}); target != "" && !ok {
+		v.AddError("lang", fmt.Sprintf("the file is translated into %s, not %s", doc.TargetLanguage, lang))
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	units, err := app.models.Translations.Units(lang)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	current := make(map[string]*data.TranslationUnit, len(units))
+	for _, u := range units {
+		current[unitID(u.Type, u.ID)] = u
+	}
+
+	summary := map[string]int{}
+	changes := []importChange{}
+	var apply []*data.TranslationUnit
+	for _, unit := range doc.Units {
+		change := importChange{Unit: unit.ID, Source: unit.Source, New: unit.Target}
+		u, ok := current[unit.ID]
+		switch {
+		case !ok:
+			change.Status = importUnknown
+		case strings.TrimSpace(unit.Target) == "":
+			change.Status = importUntranslated
+		case unit.Source != u.Source:
+			change.Status = importSourceChanged
+		case u.Target == nil:
+			change.Status = importAdded
+		case *u.Target == unit.Target:
+			change.Status = importUnchanged
+		default:
+			change.Status = importChanged
+		}
+		if ok {
+			change.Old = u.Target
+		}
+
+		if change.Status == importAdded || change.Status == importChanged {
+			target := unit.Target
+			t := &data.TranslationUnit{
+				Type:        u.Type,
+				ID:          u.ID,
+				SourceImage: u.SourceImage,
+				Target:      &target,
+				TargetImage: u.TargetImage,
+			}
+			uv := validator.New()
+			data.ValidateTranslationUnit(uv, lang, t)
+			if uv.Valid() {
+				apply = append(apply, t)
+			} else {
+				change.Status, change.Errors = importRejected, uv.Errors
+			}
+		}
+		summary[change.Status]++
+
+		if change.Status == importUnchanged || change.Status == importUntranslated {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	// the changes of a translator are saved as proposals for a reviewer to approve.
+	proposed := !dryRun && app.translatorOnly(r)
+	switch {
+	case dryRun || len(apply) == 0:
+	case proposed:
+		user := app.contextGetUser(r)
+		proposals := make([]*data.Proposal, len(apply))
+		for i, u := range apply {
+			image := u.SourceImage
+			if u.TargetImage != nil {
+				image = *u.TargetImage
+			}
+			proposals[i] = &data.Proposal{Type: u.Type, EntityID: u.ID, Language: lang, Text: *u.Target, Image: image, ProposedBy: &user.ID}
+		}
+		err = app.models.Proposals.Insert(proposals...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	default:
+		err = app.models.Translations.Import(lang, apply)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	result := envelope{
+		"language": lang,
+		"dry_run":  dryRun,
+		"proposed": proposed,
+		"summary":  summary,
+		"changes":  changes,
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"import": result}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
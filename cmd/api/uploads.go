@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"greenlight.abdulalsh.com/internal/data"
+	"greenlight.abdulalsh.com/internal/imaging"
+	"image"
+	"io"
+	"net/http"
+)
+
+// maxImagePixels caps the dimensions of an uploaded image, since a small compressed file
+// can decode into a huge bitmap. 24 megapixels (6000x4000) decode and scale down in
+// about a second and a half, well within the write timeout.
+const maxImagePixels = 24_000_000
+
+// createUploadHandler stores an image sent as the "file" field of a multipart form along
+// with a thumbnail of it, and returns their URLs for use as a category or item image.
+func (app *application) createUploadHandler(w http.ResponseWriter, r *http.Request) {
+	maxSize := app.config.uploads.maxSize
+	// leave some room on top of the file for the rest of the multipart body.
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+	err := r.ParseMultipartForm(maxSize)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.fileTooLargeResponse(w, r)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("the image must be sent in the file field"))
+		return
+	}
+	defer file.Close()
+	if header.Size > maxSize {
+		app.fileTooLargeResponse(w, r)
+		return
+	}
+	content, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if int64(len(content)) > maxSize {
+		app.fileTooLargeResponse(w, r)
+		return
+	}
+
+	// Trust the content rather than the Content-Type sent by the client.
+	contentType := http.DetectContentType(content)
+	if imaging.Extension(contentType) == "" {
+		app.unsupportedMediaTypeResponse(w, r, contentType)
+		return
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusUnprocessableEntity, "the file is not a valid image")
+		return
+	}
+	if config.Width*config.Height > maxImagePixels {
+		app.errorErrResponse(w, r, http.StatusUnprocessableEntity, "the image dimensions are too large")
+		return
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		app.errorErrResponse(w, r, http.StatusUnprocessableEntity, "the file is not a valid image")
+		return
+	}
+	var thumbnail bytes.Buffer
+	thumbnailType, err := imaging.Encode(&thumbnail, imaging.Thumbnail(img, app.config.uploads.thumbnailSize), contentType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	name, err := randomName()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	upload := &data.Upload{
+		StorageKey:   name + imaging.Extension(contentType),
+		ThumbnailKey: name + "_thumb" + imaging.Extension(thumbnailType),
+		ContentType:  contentType,
+		Size:         int64(len(content)),
+		Width:        config.Width,
+		Height:       config.Height,
+	}
+	if user := app.contextGetUser(r); !user.IsAnonymous() {
+		upload.UserID = &user.ID
+	}
+
+	upload.URL, err = app.storage.Put(upload.StorageKey, bytes.NewReader(content))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	upload.ThumbnailURL, err = app.storage.Put(upload.ThumbnailKey, &thumbnail)
+	if err != nil {
+		app.removeFiles(upload.StorageKey)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.models.Uploads.Insert(upload)
+	if err != nil {
+		app.removeFiles(upload.StorageKey, upload.ThumbnailKey)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"upload": upload}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// randomName returns a random file name, so that the URLs of the uploads can't be guessed.
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// removeFiles deletes stored files, logging the ones that couldn't be removed.
+func (app *application) removeFiles(keys ...string) {
+	for _, key := range keys {
+		err := app.storage.Delete(key)
+		if err != nil {
+			app.logger.Error(err.Error(), "key", key)
+		}
+	}
+}
+
+// removeOrphanedUploads deletes, in the background, the uploads behind the images of a
+// deleted category or item that nothing else uses anymore.
+func (app *application) removeOrphanedUploads(images []string) {
+	if len(images) == 0 {
+		return
+	}
+	app.background(func() {
+		keys, err := app.models.Uploads.DeleteUnreferenced(images)
+		if err != nil {
+			app.logger.Error(err.Error())
+			return
+		}
+		app.removeFiles(keys...)
+	})
+}
+
+// removeAbandonedUploads is the sweeper task deleting the uploads that were replaced or
+// never used. It returns the number of files removed.
+func (app *application) removeAbandonedUploads() (int64, error) {
+	keys, err := app.models.Uploads.DeleteAbandoned(app.config.uploads.abandonedAge)
+	if err != nil {
+		return 0, err
+	}
+	app.removeFiles(keys...)
+	return int64(len(keys)), nil
+}
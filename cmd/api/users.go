@@ -40,8 +40,23 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	//insert data into db
-	err = app.models.Users.Insert(user)
+	// the user, its permissions and its activation token are saved together, so that a
+	// failure half way doesn't leave behind a user who can never be activated.
+	var token *data.Token
+	err = app.models.Atomic(func(tx data.Models) error {
+		//insert data into db
+		err := tx.Users.Insert(user)
+		if err != nil {
+			return err
+		}
+		// Add the "movies:read" and "catalog:read" permissions for the new user.
+		err = tx.Permissions.AddForUser(user.ID, data.MoviesRead, data.CatalogRead)
+		if err != nil {
+			return err
+		}
+		token, err = tx.Token.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+		return err
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -53,18 +68,6 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
-	// Add the "movies:read" permission for the new user.
-	err = app.models.Permissions.AddForUser(user.ID, data.MoviesRead)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	token, err := app.models.Token.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
 
 	// Use the background helper to execute an anonymous function that sends the welcome
 	// email. which contains panic recovery
@@ -121,8 +124,15 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	user.Activated = true
 
 	// Save the updated user record in our database, checking for any edit conflicts in
-	// the same way that we did for our movie records.
-	err = app.models.Users.Update(user)
+	// the same way that we did for our movie records, and delete all activation tokens
+	// for the user in the same transaction.
+	err = app.models.Atomic(func(tx data.Models) error {
+		err := tx.Users.Update(user)
+		if err != nil {
+			return err
+		}
+		return tx.Token.DeleteAllForUser(data.ScopeActivation, user.ID)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -132,13 +142,6 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
-	// If everything went successfully, then we delete all activation tokens for the
-	// user.
-	err = app.models.Token.DeleteAllForUser(data.ScopeActivation, user.ID)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
 	td := app.newTemplateData(r)
 	td.User = *user
 	app.render(w, r, http.StatusAccepted, "user_activated.gohtml", td)
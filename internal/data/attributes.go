@@ -0,0 +1,429 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"greenlight.abdulalsh.com/internal/validator"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The types an attribute can have.
+const (
+	AttributeNumber  = "number"
+	AttributeText    = "text"
+	AttributeBoolean = "boolean"
+	AttributeEnum    = "enum"
+	AttributeMoney   = "money" // {"amount": 12.5, "currency": "SAR"}
+)
+
+// The operators of the attribute filters of the item listings, e.g. ?attr.price.min=10
+const (
+	FilterEquals = "eq"
+	FilterMin    = "min"
+	FilterMax    = "max"
+)
+
+var (
+	AttributeKeyRX = regexp.MustCompile("^[a-z][a-z0-9_]{0,49}$")
+	CurrencyRX     = regexp.MustCompile("^[A-Z]{3}$")
+)
+
+var (
+	ErrDuplicateAttribute = errors.New("duplicate attribute")
+	ErrInvalidAttributes  = errors.New("invalid attributes")
+)
+
+// Attribute is the definition of a custom attribute of the items of a category, such as
+// a price or an SKU. The label is translatable, Label being the one picked along the
+// language chain and Labels holding every translation of it.
+type Attribute struct {
+	ID         int64             `json:"id"`
+	CategoryID int64             `json:"category_id"`
+	Key        string            `json:"key"` // the key of the value in the attributes of an item
+	Type       string            `json:"type"`
+	Required   bool              `json:"required"`
+	Options    []string          `json:"options,omitempty"` // the permitted values of an enum
+	Label      string            `json:"label"`
+	Language   string            `json:"language"` // the language of Label
+	Labels     map[string]string `json:"labels"`
+	CreatedAt  time.Time         `json:"-"`
+	Version    int32             `json:"version"`
+}
+
+// localize picks the label of the first language of the chain that has one, falling back
+// to the source language.
+func (a *Attribute) localize(languages []string) {
+	for _, lang := range languages {
+		if label, ok := a.Labels[lang]; ok {
+			a.Label, a.Language = label, lang
+			return
+		}
+	}
+	a.Label, a.Language = a.Labels[SourceLanguage], SourceLanguage
+}
+
+// Attributes holds the attribute values of an item keyed by attribute key. It's stored
+// in the items.attributes JSONB column.
+type Attributes map[string]any
+
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+func (a *Attributes) Scan(src any) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into Attributes", src)
+	}
+	return json.Unmarshal(b, a)
+}
+
+// AttributeFilter is a condition on an attribute value of the items being listed. Min
+// and max compare numbers and money amounts, equals compares the value as text.
+type AttributeFilter struct {
+	Key   string `json:"key"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+func ValidateAttribute(v *validator.Validator, a *Attribute) {
+	v.Check(a.Key != "", "key", "must be provided")
+	v.Check(validator.Matches(a.Key, AttributeKeyRX), "key", "must start with a lowercase letter and contain only lowercase letters, digits and underscores")
+	v.Check(validator.PermittedValues(a.Type, AttributeNumber, AttributeText, AttributeBoolean, AttributeEnum, AttributeMoney), "type", "must be number, text, boolean, enum or money")
+	if a.Type == AttributeEnum {
+		v.Check(len(a.Options) > 0, "options", "must contain at least one option")
+		v.Check(len(a.Options) <= 100, "options", "must not contain more than 100 options")
+		v.Check(validator.Unique(a.Options), "options", "must not contain duplicate values")
+		for _, o := range a.Options {
+			v.Check(o != "" && len(o) <= 100, "options", "must contain non empty values of at most 100 bytes")
+		}
+	} else {
+		v.Check(len(a.Options) == 0, "options", "are only allowed for enum attributes")
+	}
+	_, ok := a.Labels[SourceLanguage]
+	v.Check(ok, "labels", "must contain a "+SourceLanguage+" label")
+	for lang, label := range a.Labels {
+		v.Check(Languages.Enabled(lang), "labels", lang+" is not a supported language")
+		v.Check(label != "" && len(label) <= 200, "labels", "must contain non empty labels of at most 200 bytes")
+	}
+}
+
+// ValidateAttributeValues checks the attribute values of an item against the schema of
+// its category. A null value is the same as a missing one, and is removed.
+func ValidateAttributeValues(v *validator.Validator, schema []*Attribute, values Attributes) {
+	defined := make(map[string]*Attribute, len(schema))
+	for _, a := range schema {
+		defined[a.Key] = a
+	}
+	for key, value := range values {
+		if _, ok := defined[key]; !ok {
+			v.AddError("attributes."+key, "is not defined for the category")
+			continue
+		}
+		if value == nil {
+			delete(values, key)
+		}
+	}
+
+	for _, a := range schema {
+		field := "attributes." + a.Key
+		value, ok := values[a.Key]
+		if !ok {
+			v.Check(!a.Required, field, "must be provided")
+			continue
+		}
+		switch a.Type {
+		case AttributeNumber:
+			n, ok := value.(float64)
+			v.Check(ok && !math.IsInf(n, 0) && !math.IsNaN(n), field, "must be a number")
+		case AttributeText:
+			s, ok := value.(string)
+			v.Check(ok, field, "must be a string")
+			v.Check(len(s) <= 1000, field, "must not be more than 1000 bytes long")
+		case AttributeBoolean:
+			_, ok := value.(bool)
+			v.Check(ok, field, "must be a boolean")
+		case AttributeEnum:
+			s, ok := value.(string)
+			v.Check(ok && validator.PermittedValues(s, a.Options...), field, "must be one of "+strings.Join(a.Options, ", "))
+		case AttributeMoney:
+			m, ok := value.(map[string]any)
+			if !ok {
+				v.AddError(field, `must be an object with an amount and a currency`)
+				continue
+			}
+			amount, ok := m["amount"].(float64)
+			v.Check(ok && amount >= 0, field, "must have an amount that is a positive number")
+			currency, ok := m["currency"].(string)
+			v.Check(ok && validator.Matches(currency, CurrencyRX), field, "must have a 3 letter ISO 4217 currency code")
+			v.Check(len(m) == 2, field, "must only have an amount and a currency")
+		}
+	}
+}
+
+// validateItemsAttributes checks the attributes of the items going into category
+// categoryID against its schema, within the transaction tx of the change. The errors are
+// added to v under items.<id>, and ErrInvalidAttributes is returned when there are any
+// so that the change is rolled back.
+func validateItemsAttributes(tx DBTX, v *validator.Validator, categoryID int64, items map[int64]Attributes) error {
+	schema, err := AttributeModel{DB: tx}.GetAllForCategory(categoryID)
+	if err != nil {
+		return err
+	}
+	for id, values := range items {
+		iv := validator.New()
+		ValidateAttributeValues(iv, schema, values)
+		for field, msg := range iv.Errors {
+			v.AddError(fmt.Sprintf("items.%d.%s", id, field), msg.(string))
+		}
+	}
+	if !v.Valid() {
+		return ErrInvalidAttributes
+	}
+	return nil
+}
+
+// validateCategoryItems checks the items of category id against the schema of category
+// categoryID, which is its own schema after it has been changed, or the schema of the
+// category the items are about to be moved to. The items are locked so that they can't
+// be changed until the transaction is done.
+func validateCategoryItems(ctx context.Context, tx DBTX, v *validator.Validator, categoryID, id int64) error {
+	rows, err := tx.QueryContext(ctx, lockCategoryItemAttributesQuery, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	items := make(map[int64]Attributes)
+	for rows.Next() {
+		var itemID int64
+		var attributes Attributes
+		if err := rows.Scan(&itemID, &attributes); err != nil {
+			return err
+		}
+		items[itemID] = attributes
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return validateItemsAttributes(tx, v, categoryID, items)
+}
+
+func ValidateAttributeFilter(v *validator.Validator, f AttributeFilter) {
+	field := "attr." + f.Key
+	v.Check(validator.Matches(f.Key, AttributeKeyRX), field, "is not a valid attribute key")
+	if f.Op == FilterMin || f.Op == FilterMax {
+		_, err := strconv.ParseFloat(f.Value, 64)
+		v.Check(err == nil, field+"."+f.Op, "must be a number")
+	}
+}
+
+type AttributeModel struct {
+	DB DBTX
+}
+
+const (
+	// the labels are aggregated into a {"en": "Price", "ar": "السعر"} object.
+	attributeSelect = `
+		SELECT
+			a.id, a.category_id, a.key, a.type, a.required, a.options, a.created_at, a.version,
+			COALESCE(jsonb_object_agg(l.code, t.label) FILTER (WHERE l.code IS NOT NULL), '{}')
+		FROM
+			category_attributes a
+		LEFT JOIN
+			category_attribute_translations t ON t.attribute_id = a.id
+		LEFT JOIN
+			languages l ON l.id = t.language_id`
+
+	getAttributesForCategoryQuery = attributeSelect + `
+		WHERE
+			a.category_id = $1
+		GROUP BY
+			a.id
+		ORDER BY
+			a.id`
+
+	getAttributeQuery = attributeSelect + `
+		WHERE
+			a.category_id = $1 AND a.key = $2
+		GROUP BY
+			a.id`
+
+	insertAttributeQuery = `
+		INSERT INTO category_attributes (category_id, key, type, required, options)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, version`
+
+	updateAttributeQuery = `
+		UPDATE category_attributes
+		SET required = $1, options = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version`
+
+	deleteAttributeLabelsQuery = `DELETE FROM category_attribute_translations WHERE attribute_id = $1`
+
+	insertAttributeLabelQuery = `
+		INSERT INTO category_attribute_translations (attribute_id, language_id, label)
+		VALUES ($1, (SELECT id FROM languages WHERE code = $2), $3)`
+
+	deleteAttributeQuery = `DELETE FROM category_attributes WHERE category_id = $1 AND key = $2`
+
+	// the values of a deleted attribute are removed from the items of the category.
+	deleteAttributeValuesQuery = `
+		UPDATE items SET attributes = attributes - $2, version = version + 1
+		WHERE category_id = $1 AND attributes ? $2`
+
+	lockCategoryItemAttributesQuery = `SELECT id, attributes FROM items WHERE category_id = $1 FOR UPDATE`
+)
+
+func scanAttribute(s interface{ Scan(...any) error }, a *Attribute) error {
+	var labels []byte
+	err := s.Scan(&a.ID, &a.CategoryID, &a.Key, &a.Type, &a.Required, pq.Array(&a.Options), &a.CreatedAt, &a.Version, &labels)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(labels, &a.Labels)
+}
+
+// GetAllForCategory returns the attribute schema of a category, with the labels picked
+// along the languages chain.
+func (m AttributeModel) GetAllForCategory(categoryID int64, languages ...string) ([]*Attribute, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, getAttributesForCategoryQuery, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attributes := []*Attribute{}
+	for rows.Next() {
+		var a Attribute
+		if err := scanAttribute(rows, &a); err != nil {
+			return nil, err
+		}
+		a.localize(languages)
+		attributes = append(attributes, &a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attributes, nil
+}
+
+func (m AttributeModel) Get(categoryID int64, key string, languages ...string) (*Attribute, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	var a Attribute
+	err := scanAttribute(m.DB.QueryRowContext(ctx, getAttributeQuery, categoryID, key), &a)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	a.localize(languages)
+	return &a, nil
+}
+
+// Insert adds an attribute to the schema of a category. It's refused with
+// ErrInvalidAttributes when the items of the category don't fit the new schema, such as
+// a required attribute they don't have, the errors being added to v.
+func (m AttributeModel) Insert(v *validator.Validator, a *Attribute) error {
+	ctx, cancel := createContext()
+	defer cancel()
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		args := []any{a.CategoryID, a.Key, a.Type, a.Required, pq.Array(a.Options)}
+		err := tx.QueryRowContext(ctx, insertAttributeQuery, args...).Scan(&a.ID, &a.CreatedAt, &a.Version)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), "category_attributes_category_id_key_key"):
+				return ErrDuplicateAttribute
+			case strings.Contains(err.Error(), "category_attributes_category_id_fkey"):
+				return ErrCategoryDoesntExist
+			default:
+				return err
+			}
+		}
+		for lang, label := range a.Labels {
+			_, err = tx.ExecContext(ctx, insertAttributeLabelQuery, a.ID, lang, label)
+			if err != nil {
+				return err
+			}
+		}
+		return validateCategoryItems(ctx, tx, v, a.CategoryID, a.CategoryID)
+	})
+}
+
+// Update saves the required flag, the options and the labels of an attribute. The key
+// and the type can't be changed since the values of the items depend on them, and like
+// Insert the update is refused when the items of the category don't fit it anymore.
+func (m AttributeModel) Update(v *validator.Validator, a *Attribute) error {
+	ctx, cancel := createContext()
+	defer cancel()
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		args := []any{a.Required, pq.Array(a.Options), a.ID, a.Version}
+		err := tx.QueryRowContext(ctx, updateAttributeQuery, args...).Scan(&a.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, deleteAttributeLabelsQuery, a.ID)
+		if err != nil {
+			return err
+		}
+		for lang, label := range a.Labels {
+			_, err = tx.ExecContext(ctx, insertAttributeLabelQuery, a.ID, lang, label)
+			if err != nil {
+				return err
+			}
+		}
+		return validateCategoryItems(ctx, tx, v, a.CategoryID, a.CategoryID)
+	})
+}
+
+// Delete removes an attribute from the schema of a category along with its values.
+func (m AttributeModel) Delete(categoryID int64, key string) error {
+	ctx, cancel := createContext()
+	defer cancel()
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		result, err := tx.ExecContext(ctx, deleteAttributeQuery, categoryID, key)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		_, err = tx.ExecContext(ctx, deleteAttributeValuesQuery, categoryID, key)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
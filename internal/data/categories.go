@@ -3,43 +3,121 @@ package data
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/lib/pq"
 	"greenlight.abdulalsh.com/internal/validator"
 	"strings"
 	"time"
 )
 
-var (
-	AllowedLanguages = []string{"ar", "en"}
-)
-
 // note that all the fields are exported so they are visible to encoding/json package
 // we added a struct tag to each field to be snake_case casing style
 type Category struct {
-	ID        int64     `json:"id"`    // Unique integer ID for the category
-	CreatedAt time.Time `json:"-"`     // Timestamp for when the category is added to our database
-	Title     string    `json:"title"` // category title
-	Language  string    `json:"language"`
+	ID        int64     `json:"id"`       // Unique integer ID for the category
+	CreatedAt time.Time `json:"-"`        // Timestamp for when the category is added to our database
+	Title     string    `json:"title"`    // category title
+	Language  string    `json:"language"` // the language that was actually served
+	Fallback  bool      `json:"fallback"` // true when Language isn't the requested language
 	Image     string    `json:"image"`
-	Version   int32     `json:"-"` // The version number starts at 1 and will be incremented each its updated
+	Slug      string    `json:"slug"`      // unique among the categories in Language, see ResolveSlug
+	ParentID  *int64    `json:"parent_id"` // nil for a top level category
+	Version   int32     `json:"version"`   // The version number starts at 1 and will be incremented each its updated
+	Position  int32     `json:"position"`  // the place of the category among its siblings, see Reorder
+	// Publishing holds the status and the schedule of the workflow
+	Publishing
+	// Children is only populated when the categories are returned as a tree
+	Children []*Category `json:"children,omitempty"`
+	// Translations holds every language version of the category when asked for with
+	// ?translations=all, a language without a translation maps to null and is also
+	// listed in MissingTranslations.
+	Translations        map[string]*CategoryTranslation `json:"translations,omitempty"`
+	MissingTranslations []string                        `json:"missing_translations,omitempty"`
+	// Items and ItemCount are only populated when they are asked for with ?include=
+	Items     []*Item `json:"items,omitempty"`
+	ItemCount *int    `json:"item_count,omitempty"`
+}
+
+// CategoryTranslation is a single language version of a category.
+type CategoryTranslation struct {
+	Title string `json:"title"`
+	Image string `json:"image"`
+}
+
+// DefaultCategoryID is the "Uncategorized" category seeded by the migrations. The
+// database refuses to delete it and items fall back to it when their category goes away.
+const DefaultCategoryID int64 = 100
+
+// CategoryMerge reports what merging a category into another one did.
+type CategoryMerge struct {
+	ItemsMoved         int64 `json:"items_moved"`
+	TranslationsMerged int64 `json:"translations_merged"`
+	ChildrenMoved      int64 `json:"children_moved"`
 }
 
+// The related data that can be embedded in a category response with ?include=
+const (
+	IncludeItems     = "items"
+	IncludeItemCount = "item_count"
+)
+
+// ValidateLanguage checks that the language is known to the Languages registry and enabled.
 func ValidateLanguage(v *validator.Validator, language string) {
-	v.Check(validator.PermittedValues(language, AllowedLanguages...), "language", language+" not an allowed language")
+	v.Check(Languages.Enabled(language), "language", language+" not an allowed language")
+}
+func ValidateCategoryInclude(v *validator.Validator, include []string) {
+	for _, i := range include {
+		v.Check(validator.PermittedValues(i, IncludeItems, IncludeItemCount), "include", i+" is not a valid include value")
+	}
 }
 func ValidateCategory(v *validator.Validator, category *Category) {
 	v.Check(category.Title != "", "title", "must be provided")
 	v.Check(len(category.Title) <= 500, "title", "must not be more than 500 bytes long")
 	v.Check(category.Language != "", "language", "must be provided")
 	v.Check(category.Image != "", "image", "must contain an image")
-	v.Check(validator.PermittedValues(category.Language, AllowedLanguages...), "language", category.Language+" not an allowed language")
+	ValidateLanguage(v, category.Language)
+	validateCategoryParent(v, category)
+}
+
+func validateCategoryParent(v *validator.Validator, category *Category) {
+	if category.ParentID != nil {
+		v.Check(*category.ParentID > 0, "parent_id", "must be a positive integer")
+		v.Check(*category.ParentID != category.ID, "parent_id", "a category can't be its own parent")
+	}
+}
+
+// ValidateCategoryTranslations validates a new category given with its translations. Each
+// translation is checked like ValidateCategory, with the errors nested under
+// translations.<language>, and the English one is required.
+func ValidateCategoryTranslations(v *validator.Validator, category *Category) {
+	v.Check(category.Translations[SourceLanguage] != nil, "translations", "must include the "+SourceLanguage+" translation")
+	for _, lang := range sortedKeys(category.Translations) {
+		t := category.Translations[lang]
+		key := "translations." + lang
+		if t == nil {
+			v.AddError(key, "must not be null")
+			continue
+		}
+		tv := validator.New()
+		ValidateCategory(tv, &Category{Title: t.Title, Image: t.Image, Language: lang})
+		if !tv.Valid() {
+			v.Errors[key] = tv.Errors
+		}
+	}
+	validateCategoryParent(v, category)
 }
 
 type CategoryModel struct {
-	DB *sql.DB
+	DB DBTX
+	// publishedOnly hides the categories the readers may not see, see Models.Published.
+	publishedOnly bool
 }
 
 const (
-	insertCategoryQuery = `INSERT INTO categories(version) values (1) RETURNING id, created_at, version`
+	// a new category goes after its siblings.
+	insertCategoryQuery = `
+		INSERT INTO categories(version, parent_id, position, status, publish_at, unpublish_at)
+		VALUES (1, $1, (SELECT COALESCE(max(position), 0) + 1 FROM categories WHERE parent_id IS NOT DISTINCT FROM $1), $2, $3, $4)
+		RETURNING id, created_at, version, position`
 
 	insertCategoryTranslationQuery = `INSERT INTO category_translations (category_id,language_id,translation,image) values ($1,(SELECT id FROM languages WHERE code = $2),$3,$4)`
 
@@ -52,73 +130,255 @@ const (
 	ON CONFLICT (category_id, language_id)
 	DO UPDATE SET translation = EXCLUDED.translation, image = EXCLUDED.image;`
 
+	// a category moved under another parent goes after its new siblings.
+	updateCategoryParentQuery = `
+		UPDATE categories SET
+			position = CASE WHEN parent_id IS NOT DISTINCT FROM $1 THEN position
+				ELSE (SELECT COALESCE(max(position), 0) + 1 FROM categories WHERE parent_id IS NOT DISTINCT FROM $1) END,
+			parent_id = $1
+		WHERE id = $2`
+
+	// bumpCategoryVersionQuery is run first by every update, so that an update made from
+	// a stale copy of the category fails instead of overwriting the newer one. It also
+	// saves the publishing state.
+	bumpCategoryVersionQuery = `
+		UPDATE categories SET version = version + 1, status = $3, publish_at = $4, unpublish_at = $5
+		WHERE id = $1 AND version = $2
+		RETURNING version`
+	// bumpCategoryVersionAnyQuery is used by the changes which don't start from a copy
+	// of the category, such as imports and merges.
+	bumpCategoryVersionAnyQuery = `UPDATE categories SET version = version + 1 WHERE id = $1`
+
+	// categoryTranslationLateral picks the translation of category c in the first language
+	// of the chain $1 which has one, so a missing translation falls back to the next
+	// language instead of dropping the category. It is joined as ct.
+	categoryTranslationLateral = `
+		LATERAL (
+			SELECT
+				l.code,
+				t.translation,
+				t.image,
+				t.slug,
+				t.search_text
+			FROM
+				category_translations t
+			JOIN
+				languages l ON t.language_id = l.id
+			WHERE
+				t.category_id = c.id AND l.code = ANY($1::text[])
+			ORDER BY
+				array_position($1::text[], l.code)
+			LIMIT 1
+		) ct ON true`
+
+	// categorySearchCandidates are the categories with a translation in the chain $1
+	// matching the search $2. The search can only be checked on the translation picked by
+	// categoryTranslationLateral once it has been picked, which no index can serve, so the
+	// categories are narrowed down first through the GIN index on search_text.
+	categorySearchCandidates = `
+		SELECT t.category_id
+		FROM category_translations t
+		JOIN languages l ON l.id = t.language_id
+		WHERE l.code = ANY($1::text[])
+		AND to_tsvector('simple', t.search_text) @@ plainto_tsquery('simple', catalog_normalize(l.code, $2))`
+
 	getQuery = `
 		SELECT
 			c.id AS category_id,
 			c.created_at,
 			c.version,
-			l.code AS language_code,
+			c.position,
+			c.parent_id,
+			c.status,
+			c.publish_at,
+			c.unpublish_at,
+			ct.code AS language_code,
 			ct.translation,
-			ct.image
+			ct.image,
+			ct.slug
+		FROM
+			categories c
+		JOIN ` + categoryTranslationLateral + `
+		WHERE
+			c.id = $2 AND ($3 OR ` + categoryVisibleCondition + `);`
 
+	// the tree needs every category so that no branch is cut off, a category missing all
+	// the languages of the chain is returned with an empty title and image. The hidden
+	// categories are pruned along with their sub categories by GetTree.
+	getTreeQuery = `
+		SELECT
+			c.id AS category_id,
+			c.created_at,
+			c.version,
+			c.position,
+			c.parent_id,
+			c.status,
+			c.publish_at,
+			c.unpublish_at,
+			$2 OR ` + categoryVisibleCondition + ` AS visible,
+			COALESCE(ct.code, ''),
+			COALESCE(ct.translation, ''),
+			COALESCE(ct.image, ''),
+			COALESCE(ct.slug, '')
 		FROM
 			categories c
-		JOIN
-			category_translations ct ON c.id = ct.category_id
+		LEFT JOIN ` + categoryTranslationLateral + `
+		ORDER BY
+			c.position, c.id;`
+
+	getCategoryTranslationsQuery = `
+		SELECT
+			ct.category_id,
+			l.code,
+			ct.translation,
+			ct.image
+		FROM
+			category_translations ct
 		JOIN
 			languages l ON ct.language_id = l.id
 		WHERE
-			c.id = $1 AND l.code = $2;`
+			ct.category_id = ANY($1)`
 
-	getAllQuery = `
-		SELECT
-	c.id AS category_id,
-		c.created_at,
-		c.version,
-		l.code AS language_code,
-		ct.translation,
-		ct.image
-	FROM
-	categories c
-	JOIN
-	category_translations ct ON c.id = ct.category_id
-	JOIN
-	languages l ON ct.language_id = l.id
-	WHERE
-	l.code = $1;`
-
-	deleteQuery = `DELETE FROM categories WHERE id=$1`
+	lockCategoriesQuery = `SELECT id FROM categories WHERE id = ANY($1) ORDER BY id FOR UPDATE`
+
+	// the items of $1 keep their order, after the items of $2.
+	moveCategoryItemsQuery = `
+		UPDATE items SET
+			category_id = $2,
+			position = position + (SELECT COALESCE(max(position), 0) FROM items WHERE category_id = $2),
+			version = version + 1
+		WHERE category_id = $1
+		RETURNING id, attributes`
+
+	// the translations of $1 in the languages $2 has no translation for are copied over.
+	mergeCategoryTranslationsQuery = `
+		INSERT INTO category_translations (category_id, language_id, translation, image)
+		SELECT $2, language_id, translation, image FROM category_translations WHERE category_id = $1
+		ON CONFLICT (category_id, language_id) DO NOTHING`
+
+	// the children of $1 move under $2, except for the ones $2 itself descends from which
+	// would make a cycle. These are left for the delete trigger, which moves them up to
+	// the parent of $1.
+	moveCategoryChildrenQuery = `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $2
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		UPDATE categories SET
+			parent_id = $2,
+			position = position + (SELECT COALESCE(max(position), 0) FROM categories WHERE parent_id = $2),
+			version = version + 1
+		WHERE parent_id = $1 AND id NOT IN (SELECT id FROM ancestors)`
+
+	lockCategoriesForOrderQuery = `SELECT id, parent_id FROM categories WHERE id = ANY($1) ORDER BY id FOR UPDATE`
+
+	countCategorySiblingsQuery = `SELECT count(*) FROM categories WHERE parent_id IS NOT DISTINCT FROM $1`
+
+	// the categories $1 are numbered in the order they're listed.
+	reorderCategoriesQuery = `
+		UPDATE categories c SET position = o.position, version = version + 1
+		FROM unnest($1::bigint[]) WITH ORDINALITY AS o(id, position)
+		WHERE c.id = o.id AND c.position <> o.position`
+
+	lockCategoryQuery = `SELECT id FROM categories WHERE id = $1 FOR UPDATE`
+
+	countCategoryTranslationsQuery = `SELECT count(*) FROM category_translations WHERE category_id = $1`
+
+	deleteCategoryTranslationQuery = `
+		DELETE FROM category_translations t USING languages l
+		WHERE t.language_id = l.id AND t.category_id = $1 AND l.code = $2
+		RETURNING t.image`
+
+	// the images of the translations are returned so that the uploads they point to can
+	// be cleaned up, the translations themselves are removed by the cascade.
+	deleteQuery = `
+		WITH images AS (
+			SELECT image FROM category_translations WHERE category_id = $1
+		)
+		DELETE FROM categories WHERE id = $1
+		RETURNING ARRAY(SELECT image FROM images)`
 )
 
+// Insert adds the category along with its translations, or only the Language one when it
+// has no Translations, in one transaction so that a failed translation doesn't leave a
+// category half translated.
 func (m CategoryModel) Insert(category *Category) error {
 	ctx, cancel := createContext()
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, insertCategoryQuery).Scan(&category.ID, &category.CreatedAt, &category.Version)
-	if err != nil {
-		return err
-	}
-	ctx, cancel = createContext()
-	defer cancel()
-	_, err = m.DB.ExecContext(ctx, insertCategoryTranslationQuery, category.ID, category.Language, category.Title, category.Image)
-	return err
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, insertCategoryQuery, category.ParentID, category.Status, category.PublishAt, category.UnpublishAt).Scan(&category.ID, &category.CreatedAt, &category.Version, &category.Position)
+		if err != nil {
+			return categoryParentError(err)
+		}
+		if len(category.Translations) == 0 {
+			_, err = tx.ExecContext(ctx, insertCategoryTranslationQuery, category.ID, category.Language, category.Title, category.Image)
+			return err
+		}
+		for _, lang := range sortedKeys(category.Translations) {
+			t := category.Translations[lang]
+			_, err = tx.ExecContext(ctx, insertCategoryTranslationQuery, category.ID, lang, t.Title, t.Image)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (m CategoryModel) Update(category *Category) error {
+// Update upserts the category translation, and when updateParent is set it also moves
+// the category under category.ParentID in the same transaction. It fails with
+// ErrEditConflict if the category has been updated since it was read.
+func (m CategoryModel) Update(category *Category, updateParent bool) error {
 	ctx, cancel := createContext()
 	defer cancel()
-	_, err := m.DB.ExecContext(ctx, updateCategoryTranslationQuert, category.ID, category.Language, category.Title, category.Image)
-	if err != nil {
-		switch {
-		case strings.Contains(err.Error(), `category_translations_category_id_language_id_key`) && strings.Contains(err.Error(), "duplicate"):
-			return ErrDublicateCategoryTranslation
-		default:
-			return err
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, bumpCategoryVersionQuery, category.ID, category.Version, category.Status, category.PublishAt, category.UnpublishAt).Scan(&category.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, updateCategoryTranslationQuert, category.ID, category.Language, category.Title, category.Image)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), `category_translations_category_id_language_id_key`) && strings.Contains(err.Error(), "duplicate"):
+				return ErrDublicateCategoryTranslation
+			default:
+				return err
+			}
 		}
+		if updateParent {
+			_, err = tx.ExecContext(ctx, updateCategoryParentQuery, category.ParentID, category.ID)
+			if err != nil {
+				return categoryParentError(err)
+			}
+		}
+		return nil
+	})
+}
+
+// categoryParentError maps the errors raised by the parent_id constraints and the
+// prevent_category_cycle trigger to our own errors.
+func categoryParentError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), "Category hierarchy cycle detected"):
+		return ErrCategoryCycle
+	case strings.Contains(err.Error(), "categories_parent_check"):
+		return ErrCategoryCycle
+	case strings.Contains(err.Error(), "categories_parent_id_fkey"):
+		return ErrParentCategoryDoesntExist
+	default:
+		return err
 	}
-	return nil
 }
 
-func (m CategoryModel) Get(id int64, language string) (*Category, error) {
+// Get returns the category translated into the first of the languages that it has a
+// translation for.
+func (m CategoryModel) Get(id int64, languages ...string) (*Category, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -126,7 +386,7 @@ func (m CategoryModel) Get(id int64, language string) (*Category, error) {
 	category := Category{}
 	ctx, cancel := createContext()
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, getQuery, id, language).Scan(&category.ID, &category.CreatedAt, &category.Version, &category.Language, &category.Title, &category.Image)
+	err := m.DB.QueryRowContext(ctx, getQuery, pq.Array(languages), id, !m.publishedOnly).Scan(&category.ID, &category.CreatedAt, &category.Version, &category.Position, &category.ParentID, &category.Status, &category.PublishAt, &category.UnpublishAt, &category.Language, &category.Title, &category.Image, &category.Slug)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -135,54 +395,376 @@ func (m CategoryModel) Get(id int64, language string) (*Category, error) {
 			return nil, err
 		}
 	}
+	category.Fallback = isFallback(category.Language, languages)
 	return &category, nil
 }
 
-// Add a placeholder method for deleting a specific record from the movies table.
-func (m CategoryModel) Delete(id int64) error {
+// Delete removes a category and returns the images of its translations. Its items go to
+// the default category, so it's refused with ErrInvalidAttributes when they don't fit the
+// attribute schema of the default category, the errors being added to v.
+func (m CategoryModel) Delete(v *validator.Validator, id int64) ([]string, error) {
+	if id == DefaultCategoryID {
+		return nil, ErrCantDeleteDefaultCategory
+	}
+
 	ctx, cancel := createContext()
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, deleteQuery, id)
+	var images []string
+	err := withTx(ctx, m.DB, func(tx DBTX) error {
+		err := validateCategoryItems(ctx, tx, v, DefaultCategoryID, id)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, deleteQuery, id).Scan(pq.Array(&images))
+	})
 	if err != nil {
-		if strings.Contains(err.Error(), "Cannot delete the default category") {
-			return ErrCantDeleteDefaultCategory
+		switch {
+		case strings.Contains(err.Error(), "Cannot delete the default category"):
+			return nil, ErrCantDeleteDefaultCategory
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
 		}
-		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+	return images, nil
+}
+
+// GetAll returns a page of categories translated along the languages chain. The title
+// filter is a full-text search on the translated title and is skipped when it's empty,
+// in the same way as the movies listing. Both sides of the search are normalised for the
+// served language, and a title sort uses the collation of the requested language.
+func (m CategoryModel) GetAll(languages []string, title string, filters Filters) ([]*Category, Metadata, error) {
+	sortColumn := filters.sortColumn()
+	if sortColumn == "title" {
+		sortColumn = collated("ct.translation", languages)
+	}
+	stmt := fmt.Sprintf(`
+		SELECT
+			count(*) OVER(),
+			c.id,
+			c.created_at,
+			c.version,
+			c.position,
+			c.parent_id,
+			c.status,
+			c.publish_at,
+			c.unpublish_at,
+			ct.code AS language_code,
+			ct.translation AS title,
+			ct.image,
+			ct.slug
+		FROM
+			categories c
+		JOIN `+categoryTranslationLateral+`
+		WHERE
+			($2 = '' OR c.id IN (`+categorySearchCandidates+`)
+				AND to_tsvector('simple', ct.search_text) @@ plainto_tsquery('simple', catalog_normalize(ct.code, $2)))
+		AND ($5 OR `+categoryVisibleCondition+`)
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4`, sortColumn, filters.sortDirection())
+
+	ctx, cancel := createContext()
+	defer cancel()
+
+	args := []any{pq.Array(languages), title, filters.limit(), filters.offset(), !m.publishedOnly}
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
 	if err != nil {
-		return err
+		return nil, Metadata{}, err
 	}
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	defer rows.Close()
+
+	totalRecords := 0
+	categories := []*Category{}
+	for rows.Next() {
+		var ct Category
+		if err := rows.Scan(&totalRecords, &ct.ID, &ct.CreatedAt, &ct.Version, &ct.Position, &ct.ParentID, &ct.Status, &ct.PublishAt, &ct.UnpublishAt, &ct.Language, &ct.Title, &ct.Image, &ct.Slug); err != nil {
+			return nil, Metadata{}, err
+		}
+		ct.Fallback = isFallback(ct.Language, languages)
+		categories = append(categories, &ct)
 	}
-	return nil
-}
 
-func (m CategoryModel) GetAll(language string) ([]Category, error) {
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return categories, metadata, nil
+}
 
+// GetTree returns the top level categories translated along the languages chain, with
+// their sub categories nested under Children.
+func (m CategoryModel) GetTree(languages []string) ([]*Category, error) {
 	ctx, cancel := createContext()
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, getAllQuery, language)
+	rows, err := m.DB.QueryContext(ctx, getTreeQuery, pq.Array(languages), !m.publishedOnly)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var categories []Category
+	var categories []*Category
+	hidden := make(map[int64]bool)
 	for rows.Next() {
 		var ct Category
-		if err := rows.Scan(&ct.ID, &ct.CreatedAt, &ct.Version, &ct.Language, &ct.Title, &ct.Image); err != nil {
+		var visible bool
+		if err := rows.Scan(&ct.ID, &ct.CreatedAt, &ct.Version, &ct.Position, &ct.ParentID, &ct.Status, &ct.PublishAt, &ct.UnpublishAt, &visible, &ct.Language, &ct.Title, &ct.Image, &ct.Slug); err != nil {
 			return nil, err
 		}
-		categories = append(categories, ct)
+		ct.Fallback = ct.Language != "" && isFallback(ct.Language, languages)
+		categories = append(categories, &ct)
+		if !visible {
+			hidden[ct.ID] = true
+		}
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return categories, nil
+	return pruneCategoryTree(buildCategoryTree(categories), hidden), nil
+}
+
+// pruneCategoryTree drops the hidden categories from the tree, along with everything
+// under them.
+func pruneCategoryTree(categories []*Category, hidden map[int64]bool) []*Category {
+	if len(hidden) == 0 {
+		return categories
+	}
+	kept := []*Category{}
+	for _, c := range categories {
+		if hidden[c.ID] {
+			continue
+		}
+		c.Children = pruneCategoryTree(c.Children, hidden)
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// buildCategoryTree nests each category under its parent and returns the roots. The
+// order of the input is kept for the roots and for the children of each category.
+func buildCategoryTree(categories []*Category) []*Category {
+	byID := make(map[int64]*Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	roots := []*Category{}
+	for _, c := range categories {
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+	return roots
+}
+
+// GetAllTranslations fills in the Translations and MissingTranslations of each category
+// with every translation it has, languages is the full set of languages to report on.
+func (m CategoryModel) GetAllTranslations(languages []string, categories ...*Category) error {
+	if len(categories) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Category, len(categories))
+	ids := make([]int64, len(categories))
+	for i, c := range categories {
+		c.Translations = make(map[string]*CategoryTranslation, len(languages))
+		for _, lang := range languages {
+			c.Translations[lang] = nil
+		}
+		byID[c.ID] = c
+		ids[i] = c.ID
+	}
+
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, getCategoryTranslationsQuery, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var lang string
+		var t CategoryTranslation
+		if err := rows.Scan(&id, &lang, &t.Title, &t.Image); err != nil {
+			return err
+		}
+		byID[id].Translations[lang] = &t
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range categories {
+		c.MissingTranslations = missingTranslations(c.Translations)
+	}
+	return nil
+}
+
+// Merge moves the items and children of category id into target, copies over the
+// translations target is missing and deletes id, all in one transaction. It returns the
+// images of the deleted translations so that unused uploads can be cleaned up. The
+// default category can't be merged away since it can't be deleted. Like Move, the merge
+// is refused with ErrInvalidAttributes when the items don't fit the attribute schema of
+// target, the errors being added to v.
+func (m CategoryModel) Merge(v *validator.Validator, id, target int64) (*CategoryMerge, []string, error) {
+	if id == DefaultCategoryID {
+		return nil, nil, ErrCantDeleteDefaultCategory
+	}
+
+	ctx, cancel := createContext()
+	defer cancel()
+	var merge CategoryMerge
+	var images []string
+	err := withTx(ctx, m.DB, func(tx DBTX) error {
+		// lock both categories so that nothing is added to id or target while we work.
+		rows, err := tx.QueryContext(ctx, lockCategoriesQuery, pq.Array([]int64{id, target}))
+		if err != nil {
+			return err
+		}
+		locked := 0
+		for rows.Next() {
+			locked++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if locked != 2 {
+			return ErrRecordNotFound
+		}
+
+		rows, err = tx.QueryContext(ctx, moveCategoryItemsQuery, id, target)
+		if err != nil {
+			return err
+		}
+		moved := make(map[int64]Attributes)
+		for rows.Next() {
+			var itemID int64
+			var attributes Attributes
+			if err := rows.Scan(&itemID, &attributes); err != nil {
+				rows.Close()
+				return err
+			}
+			moved[itemID] = attributes
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		merge.ItemsMoved = int64(len(moved))
+		if err := validateItemsAttributes(tx, v, target, moved); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, mergeCategoryTranslationsQuery, id, target)
+		if err != nil {
+			return err
+		}
+		merge.TranslationsMerged, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		result, err = tx.ExecContext(ctx, moveCategoryChildrenQuery, id, target)
+		if err != nil {
+			return categoryParentError(err)
+		}
+		merge.ChildrenMoved, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, bumpCategoryVersionAnyQuery, target)
+		if err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(ctx, deleteQuery, id).Scan(pq.Array(&images))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &merge, images, nil
+}
+
+// Reorder sets the positions of sibling categories to the order of ids, in one
+// transaction. ids must list every child of a single parent, or every top level
+// category, otherwise ErrIncompleteOrder is returned. The ids which don't exist are
+// returned along with ErrRecordNotFound.
+func (m CategoryModel) Reorder(ids []int64) ([]int64, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+	var missing []int64
+	err := withTx(ctx, m.DB, func(tx DBTX) error {
+		rows, err := tx.QueryContext(ctx, lockCategoriesForOrderQuery, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		parents := make(map[int64]*int64, len(ids))
+		for rows.Next() {
+			var id int64
+			var parentID *int64
+			if err := rows.Scan(&id, &parentID); err != nil {
+				rows.Close()
+				return err
+			}
+			parents[id] = parentID
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if _, ok := parents[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			return ErrRecordNotFound
+		}
+
+		parentID := parents[ids[0]]
+		for _, p := range parents {
+			if (p == nil) != (parentID == nil) || (p != nil && *p != *parentID) {
+				return ErrIncompleteOrder
+			}
+		}
+		var siblings int
+		err = tx.QueryRowContext(ctx, countCategorySiblingsQuery, parentID).Scan(&siblings)
+		if err != nil {
+			return err
+		}
+		if siblings != len(ids) {
+			return ErrIncompleteOrder
+		}
+
+		_, err = tx.ExecContext(ctx, reorderCategoriesQuery, pq.Array(ids))
+		return err
+	})
+	return missing, err
+}
+
+// DeleteTranslation removes the lang translation of a category and returns its image.
+// The source translation can't be deleted, and neither can the last one since a category
+// must be readable in some language.
+func (m CategoryModel) DeleteTranslation(id int64, lang string) (string, error) {
+	queries := translationDeleteQueries{
+		lock:   lockCategoryQuery,
+		count:  countCategoryTranslationsQuery,
+		delete: deleteCategoryTranslationQuery,
+		bump:   bumpCategoryVersionAnyQuery,
+	}
+	return deleteTranslation(m.DB, queries, id, lang)
 }
@@ -3,14 +3,72 @@ package data
 import (
 	"context"
 	"errors"
+	"greenlight.abdulalsh.com/internal/validator"
+	"slices"
 	"time"
 )
 
+// TranslationsAll is the value of the ?translations= query string parameter which asks for
+// every translation of a category or an item.
+const TranslationsAll = "all"
+
 func createContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 3*time.Second)
 
 }
 
+// isFallback reports whether the served language isn't the first, requested, language
+// of the chain.
+func isFallback(served string, languages []string) bool {
+	return len(languages) > 0 && served != languages[0]
+}
+
+// missingTranslations returns the sorted languages which have no translation in the map.
+func missingTranslations[T any](translations map[string]*T) []string {
+	missing := []string{}
+	for lang, t := range translations {
+		if t == nil {
+			missing = append(missing, lang)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// sortedKeys returns the languages of a translations map in a stable order.
+func sortedKeys[T any](translations map[string]*T) []string {
+	keys := make([]string, 0, len(translations))
+	for lang := range translations {
+		keys = append(keys, lang)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// collations are the ICU collations created along with the search normalisation, the
+// languages without their own collation are sorted with catalog_root.
+var collations = map[string]string{
+	"en": "catalog_en",
+	"ar": "catalog_ar",
+}
+
+// collated returns column in the collation of the first, requested, language of the
+// chain, for sorting the translated titles and names.
+func collated(column string, languages []string) string {
+	collation := "catalog_root"
+	if len(languages) > 0 {
+		if c, ok := collations[languages[0]]; ok {
+			collation = c
+		}
+	}
+	return column + " COLLATE " + collation
+}
+
+// ValidateTranslationsParam checks the value of the ?translations= query string parameter.
+func ValidateTranslationsParam(v *validator.Validator, translations string) {
+	v.Check(validator.PermittedValues(translations, "", TranslationsAll), "translations", "must be "+TranslationsAll)
+}
+
 // Errors
 var (
 	ErrDublicateCategoryTranslation = errors.New("duplicate category translation")
@@ -20,4 +78,9 @@ var (
 	ErrInvalidRuntimeFormat         = errors.New("invalid runtime format")
 	ErrCantDeleteDefaultCategory    = errors.New("can't delete default category")
 	ErrCategoryDoesntExist          = errors.New("category does not exist")
+	ErrParentCategoryDoesntExist    = errors.New("parent category does not exist")
+	ErrCategoryCycle                = errors.New("category hierarchy cycle")
+	ErrIncompleteOrder              = errors.New("incomplete order")
+	ErrSourceTranslation            = errors.New("can't delete the source translation")
+	ErrLastTranslation              = errors.New("can't delete the last translation")
 )
@@ -2,8 +2,12 @@ package data
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/lib/pq"
 	"greenlight.abdulalsh.com/internal/validator"
+	"slices"
 	"strings"
 	"time"
 )
@@ -18,9 +22,29 @@ type Item struct {
 	CategoryID int64     `json:"category_id"` // Category ID for the item
 	Category   string    `json:"category,omitempty"`
 	CreatedAt  time.Time `json:"-"`        // Timestamp for when the item is added to our database
+	Version    int32     `json:"version"`  // incremented every time the item or one of its translations is updated
+	Position   int32     `json:"position"` // the place of the item within its category, see Reorder
 	Name       string    `json:"name"`     // Item name
 	Image      string    `json:"image"`    // Item image
-	Language   string    `json:"language"` // Language code
+	Slug       string    `json:"slug"`     // unique among the items in Language, see ResolveSlug
+	Language   string    `json:"language"` // the language that was actually served
+	// Publishing holds the status and the schedule of the workflow
+	Publishing
+	// Attributes holds the values of the custom attributes defined by the category
+	Attributes Attributes `json:"attributes"`
+	// Tags holds the slugs of the tags of the item
+	Tags     []string `json:"tags"`
+	Fallback bool     `json:"fallback"` // true when Language isn't the requested language
+	// Translations holds every language version of the item when asked for with
+	// ?translations=all, in the same way as for categories.
+	Translations        map[string]*ItemTranslation `json:"translations,omitempty"`
+	MissingTranslations []string                    `json:"missing_translations,omitempty"`
+}
+
+// ItemTranslation is a single language version of an item.
+type ItemTranslation struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
 }
 
 // ValidateItem validates the item fields.
@@ -29,109 +53,270 @@ func ValidateItem(v *validator.Validator, item *Item) {
 	v.Check(len(item.Name) <= 500, "name", "must not be more than 500 bytes long")
 	v.Check(item.Language != "", "language", "must be provided")
 	v.Check(item.Image != "", "image", "must contain an image")
-	v.Check(validator.PermittedValues(item.Language, AllowedLanguages...), "language", item.Language+" not an allowed language")
+	ValidateLanguage(v, item.Language)
+	ValidateItemTags(v, item.Tags)
+}
+
+// ValidateItemTranslations validates a new item given with its translations, in the same
+// way as ValidateCategoryTranslations.
+func ValidateItemTranslations(v *validator.Validator, item *Item) {
+	v.Check(item.Translations[SourceLanguage] != nil, "translations", "must include the "+SourceLanguage+" translation")
+	for _, lang := range sortedKeys(item.Translations) {
+		t := item.Translations[lang]
+		key := "translations." + lang
+		if t == nil {
+			v.AddError(key, "must not be null")
+			continue
+		}
+		tv := validator.New()
+		ValidateItem(tv, &Item{Name: t.Name, Image: t.Image, Language: lang})
+		if !tv.Valid() {
+			v.Errors[key] = tv.Errors
+		}
+	}
+	ValidateItemTags(v, item.Tags)
 }
 
 type ItemModel struct {
-	DB *sql.DB
+	DB DBTX
+	// publishedOnly hides the items the readers may not see, see Models.Published.
+	publishedOnly bool
 }
 
 const (
-	insertItemQuery            = `INSERT INTO items(category_id) VALUES ($1) RETURNING id, created_at`
+	insertItemQuery            = `INSERT INTO items(category_id, attributes, position, status, publish_at, unpublish_at) VALUES ($1, $2, (SELECT COALESCE(max(position), 0) + 1 FROM items WHERE category_id = $1), $3, $4, $5) RETURNING id, created_at, version, position`
 	insertItemTranslationQuery = `INSERT INTO item_translations (item_id, language_id, translation, image) VALUES ($1, (SELECT id FROM languages WHERE code = $2), $3, $4)`
 	upsertItemTranslationQuery = `INSERT INTO item_translations (item_id, language_id, translation, image) VALUES ($1, (SELECT id FROM languages WHERE code = $2), $3, $4) ON CONFLICT (item_id, language_id) DO UPDATE SET translation = EXCLUDED.translation, image = EXCLUDED.image`
-	updateCategoryId           = "update items set category_id = $1 where id = $2"
-	getItemQuery               = `
-		SELECT
-    i.id,
-    i.category_id,
-    i.created_at,
-    it.translation AS title,
-    it.image,
-    l.code AS language,
-    ct.translation AS translation
-FROM
-    items i
-        JOIN
-    item_translations it ON i.id = it.item_id
-        JOIN
-    languages l ON it.language_id = l.id
-        JOIN
-        category_translations ct ON ct.category_id = i.category_id and ct.language_id=it.language_id
-WHERE
-    i.id = $1 AND l.code = $2`
-
-	getAllItemsQuery = `
+	// an item moved to another category goes after the items already there.
+	updateCategoryId = `
+		UPDATE items SET
+			position = CASE WHEN category_id = $1 THEN position
+				ELSE (SELECT COALESCE(max(position), 0) + 1 FROM items WHERE category_id = $1) END,
+			category_id = $1
+		WHERE id = $2`
+	// bumpItemVersionQuery is run first by every update, so that an update made from a
+	// stale copy of the item fails instead of overwriting the newer one. It also saves
+	// the attributes and the publishing state.
+	bumpItemVersionQuery    = `UPDATE items SET version = version + 1, attributes = $3, status = $4, publish_at = $5, unpublish_at = $6 WHERE id = $1 AND version = $2 RETURNING version`
+	bumpItemVersionAnyQuery = `UPDATE items SET version = version + 1 WHERE id = $1`
+	// the moved items go after the items already in the category, in the order given.
+	moveItemsQuery = `
+		UPDATE items SET
+			category_id = $1,
+			position = (SELECT COALESCE(max(position), 0) FROM items WHERE category_id = $1) + array_position($2, id),
+			version = version + 1
+		WHERE id = ANY($2)
+		RETURNING id, attributes`
+	// itemSearchCandidates are the items with a translation in the chain $1 matching the
+	// search $2, found through the GIN index on search_text in the same way as
+	// categorySearchCandidates.
+	itemSearchCandidates = `
+		SELECT t.item_id
+		FROM item_translations t
+		JOIN languages l ON l.id = t.language_id
+		WHERE l.code = ANY($1::text[])
+		AND to_tsvector('simple', t.search_text) @@ plainto_tsquery('simple', catalog_normalize(l.code, $2))`
+	// itemTranslationLateral picks the translation of item i in the first language of the
+	// chain $1 which has one, in the same way as categoryTranslationLateral. It is joined
+	// as it.
+	itemTranslationLateral = `
+		LATERAL (
+			SELECT
+				l.code,
+				t.translation,
+				t.image,
+				t.slug,
+				t.search_text
+			FROM
+				item_translations t
+			JOIN
+				languages l ON t.language_id = l.id
+			WHERE
+				t.item_id = i.id AND l.code = ANY($1::text[])
+			ORDER BY
+				array_position($1::text[], l.code)
+			LIMIT 1
+		) it ON true`
+
+	// attributeFiltersCondition keeps the items i matching every filter of the $6 JSON
+	// array of AttributeFilter. The value of a money attribute is its amount, and min/max
+	// only match numeric values so that a text value never fails the cast.
+	attributeFiltersCondition = `NOT EXISTS (
+			SELECT 1
+			FROM jsonb_to_recordset($6::jsonb) AS f(key text, op text, value text)
+			CROSS JOIN LATERAL (
+				SELECT
+					a.v #>> '{}' AS text,
+					CASE WHEN jsonb_typeof(a.v) = 'number' THEN (a.v #>> '{}')::numeric END AS number
+				FROM
+					(SELECT COALESCE(i.attributes->f.key->'amount', i.attributes->f.key) AS v) a
+			) a
+			WHERE (CASE f.op
+				WHEN 'eq' THEN a.text = f.value
+				WHEN 'min' THEN a.number >= f.value::numeric
+				WHEN 'max' THEN a.number <= f.value::numeric
+			END) IS NOT TRUE
+		)`
+
+	// itemFromQuery joins an item to its translation and to the title of its category,
+	// both picked along the language chain $1.
+	itemFromQuery = `
+		FROM
+			items i
+		JOIN ` + itemTranslationLateral + `
+		JOIN
+			categories c ON c.id = i.category_id
+		LEFT JOIN ` + categoryTranslationLateral
+
+	getItemQuery = `
 		SELECT
 			i.id,
 			i.category_id,
 			i.created_at,
-			it.translation AS title,
+			i.version,
+			i.position,
+			i.attributes,
+			i.status,
+			i.publish_at,
+			i.unpublish_at,
+			` + itemTagsColumn + ` AS tags,
+			it.translation AS name,
 			it.image,
-			l.code AS language,
-		    ct.translation AS translation
+			it.slug,
+			it.code AS language,
+			COALESCE(ct.translation, '') AS category
+		` + itemFromQuery + `
+		WHERE
+			i.id = $2 AND ($3 OR ` + itemVisibleCondition + ` AND ` + categoryVisibleCondition + `)`
 
+	getItemsForCategoriesQuery = `
+		SELECT
+			i.id,
+			i.category_id,
+			i.created_at,
+			i.version,
+			i.position,
+			i.attributes,
+			i.status,
+			i.publish_at,
+			i.unpublish_at,
+			` + itemTagsColumn + ` AS tags,
+			it.translation AS name,
+			it.image,
+			it.slug,
+			it.code AS language
 		FROM
 			items i
-		JOIN
-			item_translations it ON i.id = it.item_id
+		JOIN ` + itemTranslationLateral + `
+		WHERE
+			i.category_id = ANY($2) AND ($3 OR ` + itemVisibleCondition + `)
+		ORDER BY
+			i.position, i.id`
+	lockItemQuery = `SELECT id FROM items WHERE id = $1 FOR UPDATE`
+
+	countItemTranslationsQuery = `SELECT count(*) FROM item_translations WHERE item_id = $1`
+
+	deleteItemTranslationQuery = `
+		DELETE FROM item_translations t USING languages l
+		WHERE t.language_id = l.id AND t.item_id = $1 AND l.code = $2
+		RETURNING t.image`
+
+	lockCategoryItemsQuery = `SELECT id FROM items WHERE category_id = $1 ORDER BY id FOR UPDATE`
+	// the items $1 are numbered in the order they're listed.
+	reorderItemsQuery = `
+		UPDATE items i SET position = o.position, version = version + 1
+		FROM unnest($1::bigint[]) WITH ORDINALITY AS o(id, position)
+		WHERE i.id = o.id AND i.position <> o.position`
+	getItemTranslationsQuery = `
+		SELECT
+			it.item_id,
+			l.code,
+			it.translation,
+			it.image
+		FROM
+			item_translations it
 		JOIN
 			languages l ON it.language_id = l.id
-		JOIN
-        category_translations ct ON ct.category_id = i.category_id and ct.language_id=it.language_id
 		WHERE
-			l.code = $1`
-	deleteItemQuery = `DELETE FROM items WHERE id = $1`
+			it.item_id = ANY($1)`
+	countItemsForCategoriesQuery = `SELECT i.category_id, count(*) FROM items i WHERE i.category_id = ANY($1) AND ($2 OR ` + itemVisibleCondition + `) GROUP BY i.category_id`
+	deleteItemQuery              = `
+		WITH images AS (
+			SELECT image FROM item_translations WHERE item_id = $1
+		)
+		DELETE FROM items WHERE id = $1
+		RETURNING ARRAY(SELECT image FROM images)`
 )
 
+// Insert adds the item along with its translations, or only the Language one when it has
+// no Translations, in the same way as the categories.
 func (m ItemModel) Insert(item *Item) error {
 	ctx, cancel := createContext()
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, insertItemQuery, item.CategoryID).Scan(&item.ID, &item.CreatedAt)
-	if err != nil {
-		return err
-	}
-	ctx, cancel = createContext()
-	defer cancel()
-	_, err = m.DB.ExecContext(ctx, insertItemTranslationQuery, item.ID, item.Language, item.Name, item.Image)
-	return err
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, insertItemQuery, item.CategoryID, item.Attributes, item.Status, item.PublishAt, item.UnpublishAt).Scan(&item.ID, &item.CreatedAt, &item.Version, &item.Position)
+		if err != nil {
+			return err
+		}
+		if len(item.Translations) == 0 {
+			_, err = tx.ExecContext(ctx, insertItemTranslationQuery, item.ID, item.Language, item.Name, item.Image)
+			if err != nil {
+				return err
+			}
+		}
+		for _, lang := range sortedKeys(item.Translations) {
+			t := item.Translations[lang]
+			_, err = tx.ExecContext(ctx, insertItemTranslationQuery, item.ID, lang, t.Name, t.Image)
+			if err != nil {
+				return err
+			}
+		}
+		return setItemTags(ctx, tx, item.ID, item.Tags)
+	})
 }
 
+// Update saves the translation of an item and, when updateCategory is set, moves it to
+// item.CategoryID. It fails with ErrEditConflict if the item has been updated since it
+// was read, in the same way as the movies.
 func (m ItemModel) Update(item *Item, updateCategory bool) error {
 	ctx, cancel := createContext()
 	defer cancel()
-	tx, err := m.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-	_, err = tx.Exec(upsertItemTranslationQuery, item.ID, item.Language, item.Name, item.Image)
-	if err != nil {
-
-		if strings.Contains(err.Error(), `item_translations_item_id_language_id_key`) && strings.Contains(err.Error(), "duplicate") {
-			return ErrDuplicateItemTranslation
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, bumpItemVersionQuery, item.ID, item.Version, item.Attributes, item.Status, item.PublishAt, item.UnpublishAt).Scan(&item.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
 		}
-
-		return err
-	}
-	if updateCategory {
-
-		_, err = tx.Exec(updateCategoryId, item.CategoryID, item.ID)
+		_, err = tx.ExecContext(ctx, upsertItemTranslationQuery, item.ID, item.Language, item.Name, item.Image)
 		if err != nil {
-			if err.Error() == "pq: insert or update on table \"items\" violates foreign key constraint \"items_category_id_fkey\"" {
-				return ErrCategoryDoesntExist
+
+			if strings.Contains(err.Error(), `item_translations_item_id_language_id_key`) && strings.Contains(err.Error(), "duplicate") {
+				return ErrDuplicateItemTranslation
 			}
+
 			return err
 		}
-	}
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-	return nil
+		if updateCategory {
+
+			_, err = tx.ExecContext(ctx, updateCategoryId, item.CategoryID, item.ID)
+			if err != nil {
+				if err.Error() == "pq: insert or update on table \"items\" violates foreign key constraint \"items_category_id_fkey\"" {
+					return ErrCategoryDoesntExist
+				}
+				return err
+			}
+		}
+		return setItemTags(ctx, tx, item.ID, item.Tags)
+	})
 }
 
-func (m ItemModel) Get(id int64, language string) (*Item, error) {
+// Get returns the item translated into the first of the languages that it has a
+// translation for.
+func (m ItemModel) Get(id int64, languages ...string) (*Item, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -139,99 +324,323 @@ func (m ItemModel) Get(id int64, language string) (*Item, error) {
 	item := Item{}
 	ctx, cancel := createContext()
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, getItemQuery, id, language).Scan(&item.ID, &item.CategoryID, &item.CreatedAt, &item.Name, &item.Image, &item.Language, &item.Category)
+	err := m.DB.QueryRowContext(ctx, getItemQuery, pq.Array(languages), id, !m.publishedOnly).Scan(&item.ID, &item.CategoryID, &item.CreatedAt, &item.Version, &item.Position, &item.Attributes, &item.Status, &item.PublishAt, &item.UnpublishAt, pq.Array(&item.Tags), &item.Name, &item.Image, &item.Slug, &item.Language, &item.Category)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrRecordNotFound
 		}
 		return nil, err
 	}
+	item.Fallback = isFallback(item.Language, languages)
 	return &item, nil
 }
 
-func (m ItemModel) GetAll(language string) ([]Item, error) {
+// GetAll returns a page of items translated along the languages chain, the name filter
+// is a full-text search on the translated item name which is skipped when it's empty,
+// and a categoryID of 0 returns the items of every category. The items must match all
+// of the attribute filters and have all of the tags, like the genres of the movies. The
+// search and the name sort are normalised and collated like the categories listing.
+func (m ItemModel) GetAll(languages []string, name string, categoryID int64, attributes []AttributeFilter, tags []string, filters Filters) ([]*Item, Metadata, error) {
+	sortColumn := filters.sortColumn()
+	if sortColumn == "name" {
+		sortColumn = collated("it.translation", languages)
+	}
+	stmt := fmt.Sprintf(`
+		SELECT
+			count(*) OVER(),
+			i.id,
+			i.category_id,
+			i.created_at,
+			i.version,
+			i.position,
+			i.attributes,
+			i.status,
+			i.publish_at,
+			i.unpublish_at,
+			`+itemTagsColumn+` AS tags,
+			it.translation AS name,
+			it.image,
+			it.slug,
+			it.code AS language,
+			COALESCE(ct.translation, '') AS category
+		`+itemFromQuery+`
+		WHERE
+			($2 = '' OR i.id IN (`+itemSearchCandidates+`)
+				AND to_tsvector('simple', it.search_text) @@ plainto_tsquery('simple', catalog_normalize(it.code, $2)))
+		AND (i.category_id = $3 OR $3 = 0)
+		AND `+attributeFiltersCondition+`
+		AND ($7 = '{}' OR i.id IN (
+			SELECT x.item_id FROM item_tags x JOIN tags t ON t.id = x.tag_id
+			WHERE t.slug = ANY($7)
+			GROUP BY x.item_id
+			HAVING count(*) = cardinality($7)))
+		AND ($8 OR `+itemVisibleCondition+` AND `+categoryVisibleCondition+`)
+		ORDER BY %s %s, id ASC
+		LIMIT $4 OFFSET $5`, sortColumn, filters.sortDirection())
+
 	ctx, cancel := createContext()
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, getAllItemsQuery, language)
+	if attributes == nil {
+		attributes = []AttributeFilter{}
+	}
+	// the tags are counted against the matches, so each must be given once.
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+	if tags == nil {
+		tags = []string{}
+	}
+	attributesJSON, err := json.Marshal(attributes)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
+	}
+
+	args := []any{pq.Array(languages), name, categoryID, filters.limit(), filters.offset(), attributesJSON, pq.Array(tags), !m.publishedOnly}
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
-	var items []Item
+	totalRecords := 0
+	items := []*Item{}
 	for rows.Next() {
 		var item Item
-		if err := rows.Scan(&item.ID, &item.CategoryID, &item.CreatedAt, &item.Name, &item.Image, &item.Language, &item.Category); err != nil {
-			return nil, err
+		if err := rows.Scan(&totalRecords, &item.ID, &item.CategoryID, &item.CreatedAt, &item.Version, &item.Position, &item.Attributes, &item.Status, &item.PublishAt, &item.UnpublishAt, pq.Array(&item.Tags), &item.Name, &item.Image, &item.Slug, &item.Language, &item.Category); err != nil {
+			return nil, Metadata{}, err
 		}
-		items = append(items, item)
+		item.Fallback = isFallback(item.Language, languages)
+		items = append(items, &item)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
-	return items, nil
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items, metadata, nil
 }
 
-func (m ItemModel) Delete(id int64) error {
+// Delete removes an item and returns the images of its translations.
+func (m ItemModel) Delete(id int64) ([]string, error) {
 	ctx, cancel := createContext()
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, deleteItemQuery, id)
+	var images []string
+	err := m.DB.QueryRowContext(ctx, deleteItemQuery, id).Scan(pq.Array(&images))
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
+	return images, nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+// GetAllForCategories returns the items of each of the given categories translated along
+// the languages chain, keyed by the category id.
+func (m ItemModel) GetAllForCategories(languages []string, categoryIDs []int64) (map[int64][]*Item, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, getItemsForCategoriesQuery, pq.Array(languages), pq.Array(categoryIDs), !m.publishedOnly)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	defer rows.Close()
+
+	items := make(map[int64][]*Item)
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.ID, &item.CategoryID, &item.CreatedAt, &item.Version, &item.Position, &item.Attributes, &item.Status, &item.PublishAt, &item.UnpublishAt, pq.Array(&item.Tags), &item.Name, &item.Image, &item.Slug, &item.Language); err != nil {
+			return nil, err
+		}
+		item.Fallback = isFallback(item.Language, languages)
+		items[item.CategoryID] = append(items[item.CategoryID], &item)
 	}
-	return nil
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
 }
-func (m ItemModel) GetAllUntranslated(lang string) ([]Item, error) {
 
-	stmt := `
-		WITH EnglishLanguage AS (
-    SELECT id FROM public.languages WHERE code = 'en'
-)
-SELECT
-    i.id,
-    i.category_id,
-    i.created_at,
-    COALESCE(it.translation, 'Default Translation Missing') AS name,
-    COALESCE(it.image, 'Default Image URL') AS image
-FROM public.items i
-         LEFT JOIN public.item_translations it ON i.id = it.item_id AND it.language_id = (SELECT id FROM EnglishLanguage)
-WHERE NOT EXISTS (
-    SELECT 1 FROM public.item_translations WHERE item_id = i.id AND language_id = (SELECT id FROM languages where code=$1)
-);`
+// CountForCategories returns the number of items in each of the given categories, keyed
+// by the category id. Categories without any items are missing from the map.
+func (m ItemModel) CountForCategories(categoryIDs []int64) (map[int64]int, error) {
 	ctx, cancel := createContext()
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, stmt, lang)
+	rows, err := m.DB.QueryContext(ctx, countItemsForCategoriesQuery, pq.Array(categoryIDs), !m.publishedOnly)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var items []Item
+	counts := make(map[int64]int)
 	for rows.Next() {
-		var item Item
-		if err := rows.Scan(&item.ID, &item.CategoryID, &item.CreatedAt, &item.Name, &item.Image); err != nil {
+		var categoryID int64
+		var count int
+		if err := rows.Scan(&categoryID, &count); err != nil {
 			return nil, err
 		}
-		items = append(items, item)
+		counts[categoryID] = count
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return items, nil
+	return counts, nil
+}
+
+// GetAllTranslations fills in the Translations and MissingTranslations of each item with
+// every translation it has, languages is the full set of languages to report on.
+func (m ItemModel) GetAllTranslations(languages []string, items ...*Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Item, len(items))
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		item.Translations = make(map[string]*ItemTranslation, len(languages))
+		for _, lang := range languages {
+			item.Translations[lang] = nil
+		}
+		byID[item.ID] = item
+		ids[i] = item.ID
+	}
+
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, getItemTranslationsQuery, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var lang string
+		var t ItemTranslation
+		if err := rows.Scan(&id, &lang, &t.Name, &t.Image); err != nil {
+			return err
+		}
+		byID[id].Translations[lang] = &t
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		item.MissingTranslations = missingTranslations(item.Translations)
+	}
+	return nil
+}
 
+// Move moves the given items into category categoryID in one transaction. If any of the
+// items doesn't exist nothing is moved and their ids are returned along with
+// ErrRecordNotFound. The attributes of the items are checked against the schema of the
+// category, and nothing is moved either when any of them fails, the errors being added
+// to v along with ErrInvalidAttributes.
+func (m ItemModel) Move(v *validator.Validator, ids []int64, categoryID int64) ([]int64, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+	var missing []int64
+	err := withTx(ctx, m.DB, func(tx DBTX) error {
+		rows, err := tx.QueryContext(ctx, moveItemsQuery, categoryID, pq.Array(ids))
+		if err != nil {
+			if strings.Contains(err.Error(), "items_category_id_fkey") {
+				return ErrCategoryDoesntExist
+			}
+			return err
+		}
+		moved := make(map[int64]Attributes, len(ids))
+		for rows.Next() {
+			var id int64
+			var attributes Attributes
+			if err := rows.Scan(&id, &attributes); err != nil {
+				rows.Close()
+				return err
+			}
+			moved[id] = attributes
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if _, ok := moved[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			return ErrRecordNotFound
+		}
+		return validateItemsAttributes(tx, v, categoryID, moved)
+	})
+	return missing, err
+}
+
+// Reorder sets the positions of the items of category categoryID to the order of ids,
+// in one transaction. ids must list every item of the category, otherwise
+// ErrIncompleteOrder is returned. The ids which aren't items of the category are
+// returned along with ErrRecordNotFound.
+func (m ItemModel) Reorder(categoryID int64, ids []int64) ([]int64, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+	var missing []int64
+	err := withTx(ctx, m.DB, func(tx DBTX) error {
+		rows, err := tx.QueryContext(ctx, lockCategoryItemsQuery, categoryID)
+		if err != nil {
+			return err
+		}
+		inCategory := make(map[int64]bool)
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			inCategory[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if !inCategory[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			return ErrRecordNotFound
+		}
+		if len(ids) != len(inCategory) {
+			return ErrIncompleteOrder
+		}
+
+		_, err = tx.ExecContext(ctx, reorderItemsQuery, pq.Array(ids))
+		return err
+	})
+	return missing, err
+}
+
+// DeleteTranslation removes the lang translation of an item and returns its image, in
+// the same way as for the categories.
+func (m ItemModel) DeleteTranslation(id int64, lang string) (string, error) {
+	queries := translationDeleteQueries{
+		lock:   lockItemQuery,
+		count:  countItemTranslationsQuery,
+		delete: deleteItemTranslationQuery,
+		bump:   bumpItemVersionAnyQuery,
+	}
+	return deleteTranslation(m.DB, queries, id, lang)
 }
@@ -0,0 +1,220 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"greenlight.abdulalsh.com/internal/validator"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// The text directions a language can be written in.
+const (
+	DirectionLTR = "ltr"
+	DirectionRTL = "rtl"
+)
+
+// SourceLanguage is the language every category and item is created in, so it can't be
+// disabled.
+const SourceLanguage = "en"
+
+// LanguageCodeRX matches a BCP 47 primary language subtag such as "en" or "ar".
+var LanguageCodeRX = regexp.MustCompile("^[a-z]{2,3}$")
+
+var ErrDuplicateLanguage = errors.New("duplicate language")
+
+// Language is a row of the languages table along with its metadata.
+type Language struct {
+	ID        int64  `json:"id"`
+	Code      string `json:"code"`      // e.g. 'en', 'ar'
+	Name      string `json:"name"`      // e.g. 'English', 'Arabic'
+	Direction string `json:"direction"` // ltr or rtl
+	Enabled   bool   `json:"enabled"`   // disabled languages keep their translations but can't be requested or written
+	Version   int32  `json:"version"`
+}
+
+func ValidateLanguageRecord(v *validator.Validator, language *Language) {
+	v.Check(language.Code != "", "code", "must be provided")
+	v.Check(validator.Matches(language.Code, LanguageCodeRX), "code", "must be a 2 or 3 letter lowercase language code")
+	v.Check(language.Name != "", "name", "must be provided")
+	v.Check(len(language.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(validator.PermittedValues(language.Direction, DirectionLTR, DirectionRTL), "direction", "must be ltr or rtl")
+	v.Check(language.Enabled || language.Code != SourceLanguage, "enabled", "the source language can't be disabled")
+}
+
+// LanguageRegistry is an in-memory copy of the languages table. The validators and the
+// request helpers consult it rather than hitting the database on every request, and it
+// is reloaded by LanguageModel.Refresh() whenever the table changes.
+type LanguageRegistry struct {
+	mu        sync.RWMutex
+	languages []*Language
+}
+
+// Languages is the registry used throughout the application. It starts out with the
+// languages seeded by the migrations so that it's usable before the first refresh.
+var Languages = &LanguageRegistry{
+	languages: []*Language{
+		{ID: 1, Code: "en", Name: "English", Direction: DirectionLTR, Enabled: true, Version: 1},
+		{ID: 2, Code: "ar", Name: "Arabic", Direction: DirectionRTL, Enabled: true, Version: 1},
+	},
+}
+
+// Set replaces the content of the registry.
+func (r *LanguageRegistry) Set(languages []*Language) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.languages = languages
+}
+
+// All returns a copy of every language, including the disabled ones.
+func (r *LanguageRegistry) All() []Language {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	all := make([]Language, len(r.languages))
+	for i, l := range r.languages {
+		all[i] = *l
+	}
+	return all
+}
+
+// Codes returns the codes of the enabled languages, in a stable order.
+func (r *LanguageRegistry) Codes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var codes []string
+	for _, l := range r.languages {
+		if l.Enabled {
+			codes = append(codes, l.Code)
+		}
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// Get returns the language with the given code, whether it's enabled or not.
+func (r *LanguageRegistry) Get(code string) (Language, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, l := range r.languages {
+		if l.Code == code {
+			return *l, true
+		}
+	}
+	return Language{}, false
+}
+
+// Enabled reports whether code is a known and enabled language.
+func (r *LanguageRegistry) Enabled(code string) bool {
+	l, ok := r.Get(code)
+	return ok && l.Enabled
+}
+
+type LanguageModel struct {
+	DB DBTX
+}
+
+const (
+	getAllLanguagesQuery = `SELECT id, code, name, direction, enabled, version FROM languages ORDER BY id`
+	getLanguageQuery     = `SELECT id, code, name, direction, enabled, version FROM languages WHERE code = $1`
+	insertLanguageQuery  = `
+		INSERT INTO languages (code, name, direction, enabled)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, version`
+	updateLanguageQuery = `
+		UPDATE languages
+		SET name = $1, direction = $2, enabled = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version`
+)
+
+// GetAll returns every language, including the disabled ones.
+func (m LanguageModel) GetAll() ([]*Language, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, getAllLanguagesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	languages := []*Language{}
+	for rows.Next() {
+		var l Language
+		if err := rows.Scan(&l.ID, &l.Code, &l.Name, &l.Direction, &l.Enabled, &l.Version); err != nil {
+			return nil, err
+		}
+		languages = append(languages, &l)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return languages, nil
+}
+
+func (m LanguageModel) Get(code string) (*Language, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	var l Language
+	err := m.DB.QueryRowContext(ctx, getLanguageQuery, code).Scan(&l.ID, &l.Code, &l.Name, &l.Direction, &l.Enabled, &l.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &l, nil
+}
+
+func (m LanguageModel) Insert(language *Language) error {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	args := []any{language.Code, language.Name, language.Direction, language.Enabled}
+	err := m.DB.QueryRowContext(ctx, insertLanguageQuery, args...).Scan(&language.ID, &language.Version)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "languages_code_key"):
+			return ErrDuplicateLanguage
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Update renames, changes the direction of or enables/disables a language. The code
+// itself can't be changed since clients use it as the identifier.
+func (m LanguageModel) Update(language *Language) error {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	args := []any{language.Name, language.Direction, language.Enabled, language.ID, language.Version}
+	err := m.DB.QueryRowContext(ctx, updateLanguageQuery, args...).Scan(&language.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Refresh reloads the Languages registry from the languages table.
+func (m LanguageModel) Refresh() error {
+	languages, err := m.GetAll()
+	if err != nil {
+		return err
+	}
+	Languages.Set(languages)
+	return nil
+}
@@ -16,11 +16,24 @@ type Models struct {
 	Permissions   PermissionModel
 	CategoryModel CategoryModel
 	ItemModel     ItemModel
+	Languages     LanguageModel
+	Translations  TranslationModel
+	Uploads       UploadModel
+	Attributes    AttributeModel
+	Tags          TagModel
+	Proposals     ProposalModel
+
+	// db is what the models run on, see Atomic.
+	db DBTX
 }
 
 // For ease of use, we also add a New() method which returns a Models struct containing
 // the initialized MovieModel.
 func NewModels(db *sql.DB) Models {
+	return newModels(db)
+}
+
+func newModels(db DBTX) Models {
 	return Models{
 		Movies:        MovieModel{DB: db},
 		Users:         UserModel{DB: db},
@@ -28,5 +41,12 @@ func NewModels(db *sql.DB) Models {
 		Permissions:   PermissionModel{DB: db},
 		CategoryModel: CategoryModel{DB: db},
 		ItemModel:     ItemModel{DB: db},
+		Languages:     LanguageModel{DB: db},
+		Translations:  TranslationModel{DB: db},
+		Uploads:       UploadModel{DB: db},
+		Attributes:    AttributeModel{DB: db},
+		Tags:          TagModel{DB: db},
+		Proposals:     ProposalModel{DB: db},
+		db:            db,
 	}
 }
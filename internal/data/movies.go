@@ -41,7 +41,7 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 }
 
 type MovieModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 // Add a placeholder method for inserting a new record in the movies table.
@@ -2,14 +2,19 @@ package data
 
 import (
 	"context"
-	"database/sql"
 	"github.com/lib/pq"
 	"time"
 )
 
 const (
-	MoviesRead  = "movies:read"
-	MoviesWrite = "movies:write"
+	MoviesRead     = "movies:read"
+	MoviesWrite    = "movies:write"
+	LanguagesWrite = "languages:write"
+	CatalogRead    = "catalog:read"
+	CatalogWrite   = "catalog:write"
+	// CatalogTranslate lets a user upsert the non-English translations of the categories
+	// and items, without creating, moving or deleting them.
+	CatalogTranslate = "catalog:translate"
 )
 
 type Permissions []string
@@ -25,7 +30,7 @@ func (p Permissions) Include(code string) bool {
 
 // Define the PermissionModel type.
 type PermissionModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 // The GetAllForUser() method returns all permission codes for a specific user in a
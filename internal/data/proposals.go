@@ -0,0 +1,335 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"greenlight.abdulalsh.com/internal/textdiff"
+	"greenlight.abdulalsh.com/internal/validator"
+	"time"
+)
+
+// The statuses of a translation proposal. A draft is a machine translation suggestion,
+// which a translator has to submit before it's pending review.
+const (
+	ProposalDraft    = "draft"
+	ProposalPending  = "pending"
+	ProposalApproved = "approved"
+	ProposalRejected = "rejected"
+)
+
+var (
+	ErrProposalReviewed  = errors.New("proposal already reviewed")
+	ErrProposalDraft     = errors.New("proposal is a draft")
+	ErrProposalSubmitted = errors.New("proposal already submitted")
+	ErrProposalOutdated  = errors.New("proposal outdated")
+)
+
+// Proposal is a change to a category or item translation made by a translator. It's kept
+// aside until a reviewer approves it, so the readers keep getting the current
+// translation in the meantime.
+type Proposal struct {
+	ID         int64      `json:"id"`
+	Type       string     `json:"type"` // EntityCategory or EntityItem
+	EntityID   int64      `json:"entity_id"`
+	Language   string     `json:"language"`
+	Text       string     `json:"text"`
+	Image      string     `json:"image"`
+	Status     string     `json:"status"`
+	ProposedBy *int64     `json:"proposed_by"` // nil once the user has been deleted
+	ReviewedBy *int64     `json:"reviewed_by,omitempty"`
+	Note       string     `json:"note,omitempty"`     // the reason given by the reviewer
+	Provider   string     `json:"provider,omitempty"` // the machine translation provider of a suggestion
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	// Base is the translation the proposal was made against, nil when there was none.
+	Base *string `json:"base"`
+	// Current is the translation the proposal would replace, nil when there's none yet,
+	// and Diff the word changes from it to Text. They're only filled in by Get.
+	Current      *string       `json:"current,omitempty"`
+	CurrentImage *string       `json:"current_image,omitempty"`
+	Diff         []textdiff.Op `json:"diff,omitempty"`
+}
+
+func ValidateProposalStatus(v *validator.Validator, status string) {
+	v.Check(validator.PermittedValues(status, "", ProposalDraft, ProposalPending, ProposalApproved, ProposalRejected), "status", "must be draft, pending, approved or rejected")
+}
+
+type ProposalModel struct {
+	DB DBTX
+}
+
+const (
+	proposalColumns = `
+		p.id,
+		CASE WHEN p.category_id IS NOT NULL THEN 'category' ELSE 'item' END,
+		COALESCE(p.category_id, p.item_id),
+		l.code,
+		p.translation,
+		p.image,
+		p.status,
+		p.proposed_by,
+		p.reviewed_by,
+		p.note,
+		p.provider,
+		p.created_at,
+		p.reviewed_at,
+		p.base_translation`
+
+	// the current translation of the entity is kept as the base of the proposal.
+	insertProposalQuery = `
+		INSERT INTO translation_proposals (category_id, item_id, language_id, translation, image, proposed_by, provider, status, base_translation)
+		SELECT $1::bigint, $2::bigint, l.id, $4::text, $5::text, $6::bigint, $7::text, $8::text, COALESCE(
+			(SELECT translation FROM category_translations WHERE category_id = $1 AND language_id = l.id),
+			(SELECT translation FROM item_translations WHERE item_id = $2 AND language_id = l.id))
+		FROM languages l
+		WHERE l.code = $3
+		RETURNING id, status, created_at, base_translation`
+
+	// the current translation is the one of the proposal's entity in its language.
+	getProposalQuery = `
+		SELECT ` + proposalColumns + `,
+			COALESCE(ct.translation, it.translation),
+			COALESCE(ct.image, it.image)
+		FROM
+			translation_proposals p
+		JOIN
+			languages l ON l.id = p.language_id
+		LEFT JOIN
+			category_translations ct ON ct.category_id = p.category_id AND ct.language_id = p.language_id
+		LEFT JOIN
+			item_translations it ON it.item_id = p.item_id AND it.language_id = p.language_id
+		WHERE
+			p.id = $1`
+
+	// the proposals are filtered on the optional status $1, language $2, entity type $3
+	// and author $4, 0 being every author. The drafts match every author since any
+	// translator may take them up.
+	getProposalsQuery = `
+		SELECT count(*) OVER(), ` + proposalColumns + `
+		FROM
+			translation_proposals p
+		JOIN
+			languages l ON l.id = p.language_id
+		WHERE
+			(p.status = $1 OR $1 = '')
+		AND (l.code = $2 OR $2 = '')
+		AND ($3 = '' OR $3 = CASE WHEN p.category_id IS NOT NULL THEN 'category' ELSE 'item' END)
+		AND (p.proposed_by = $4 OR $4 = 0 OR p.status = 'draft')
+		ORDER BY %s %s, p.id ASC
+		LIMIT $5 OFFSET $6`
+
+	// only a pending proposal can be reviewed and only a draft submitted, the row is
+	// locked so that two users can't both act on it.
+	lockProposalQuery = `
+		SELECT ` + proposalColumns + `
+		FROM
+			translation_proposals p
+		JOIN
+			languages l ON l.id = p.language_id
+		WHERE
+			p.id = $1
+		FOR UPDATE OF p`
+
+	reviewProposalQuery = `
+		UPDATE translation_proposals SET status = $2, reviewed_by = $3, note = $4, reviewed_at = NOW()
+		WHERE id = $1
+		RETURNING reviewed_at`
+
+	// the translation a proposal replaces, locked until the approval is done.
+	lockCategoryTranslationQuery = `
+		SELECT t.translation FROM category_translations t JOIN languages l ON l.id = t.language_id
+		WHERE t.category_id = $1 AND l.code = $2
+		FOR UPDATE OF t`
+
+	lockItemTranslationQuery = `
+		SELECT t.translation FROM item_translations t JOIN languages l ON l.id = t.language_id
+		WHERE t.item_id = $1 AND l.code = $2
+		FOR UPDATE OF t`
+
+	// submitting a draft makes the submitter its author.
+	submitProposalQuery = `
+		UPDATE translation_proposals SET status = 'pending', translation = $2, image = $3, proposed_by = $4
+		WHERE id = $1`
+)
+
+func scanProposal(dest []any, p *Proposal) []any {
+	return append(dest, &p.ID, &p.Type, &p.EntityID, &p.Language, &p.Text, &p.Image, &p.Status, &p.ProposedBy, &p.ReviewedBy, &p.Note, &p.Provider, &p.CreatedAt, &p.ReviewedAt, &p.Base)
+}
+
+// Insert saves the proposals, as pending unless they're given another status, all in
+// one transaction.
+func (m ProposalModel) Insert(proposals ...*Proposal) error {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		for _, p := range proposals {
+			var categoryID, itemID *int64
+			if p.Type == EntityItem {
+				itemID = &p.EntityID
+			} else {
+				categoryID = &p.EntityID
+			}
+			status := p.Status
+			if status == "" {
+				status = ProposalPending
+			}
+			err := tx.QueryRowContext(ctx, insertProposalQuery, categoryID, itemID, p.Language, p.Text, p.Image, p.ProposedBy, p.Provider, status).Scan(&p.ID, &p.Status, &p.CreatedAt, &p.Base)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get returns the proposal along with the current translation and the diff from it.
+func (m ProposalModel) Get(id int64) (*Proposal, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := createContext()
+	defer cancel()
+
+	var p Proposal
+	dest := append(scanProposal(nil, &p), &p.Current, &p.CurrentImage)
+	err := m.DB.QueryRowContext(ctx, getProposalQuery, id).Scan(dest...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	current := ""
+	if p.Current != nil {
+		current = *p.Current
+	}
+	p.Diff = textdiff.Words(current, p.Text)
+	return &p, nil
+}
+
+// GetAll returns a page of the proposals matching the filters, the empty strings and a
+// proposedBy of 0 match everything.
+func (m ProposalModel) GetAll(status, lang, entityType string, proposedBy int64, filters Filters) ([]*Proposal, Metadata, error) {
+	stmt := fmt.Sprintf(getProposalsQuery, "p."+filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, status, lang, entityType, proposedBy, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	proposals := []*Proposal{}
+	for rows.Next() {
+		var p Proposal
+		if err := rows.Scan(scanProposal([]any{&totalRecords}, &p)...); err != nil {
+			return nil, Metadata{}, err
+		}
+		proposals = append(proposals, &p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return proposals, metadata, nil
+}
+
+// Approve applies the proposal to its translation, through the same upsert as the
+// updates, and marks it approved by the reviewer. It fails with ErrProposalReviewed when
+// the proposal isn't pending anymore, with ErrProposalDraft when it hasn't been
+// submitted yet, and with ErrProposalOutdated when the translation has changed since
+// the proposal was made.
+func (m ProposalModel) Approve(id, reviewer int64) (*Proposal, error) {
+	return m.review(id, reviewer, ProposalApproved, "")
+}
+
+// Reject marks the proposal rejected by the reviewer, with an optional note for the
+// translator. The translation is left as it is.
+func (m ProposalModel) Reject(id, reviewer int64, note string) (*Proposal, error) {
+	return m.review(id, reviewer, ProposalRejected, note)
+}
+
+func (m ProposalModel) review(id, reviewer int64, status, note string) (*Proposal, error) {
+	return m.update(id, func(ctx context.Context, tx DBTX, p *Proposal) error {
+		switch p.Status {
+		case ProposalPending:
+		case ProposalDraft:
+			return ErrProposalDraft
+		default:
+			return ErrProposalReviewed
+		}
+		if status == ProposalApproved {
+			query := lockCategoryTranslationQuery
+			if p.Type == EntityItem {
+				query = lockItemTranslationQuery
+			}
+			var current *string
+			err := tx.QueryRowContext(ctx, query, p.EntityID, p.Language).Scan(&current)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if (current == nil) != (p.Base == nil) || current != nil && *current != *p.Base {
+				return ErrProposalOutdated
+			}
+			err = upsertTranslation(ctx, tx, p.Type, p.EntityID, p.Language, p.Text, p.Image)
+			if err != nil {
+				return err
+			}
+		}
+		p.Status, p.ReviewedBy, p.Note = status, &reviewer, note
+		return tx.QueryRowContext(ctx, reviewProposalQuery, id, status, reviewer, note).Scan(&p.ReviewedAt)
+	})
+}
+
+// Submit sends a draft for review with the text and image the translator settled on,
+// making them its author. It fails with ErrProposalSubmitted when the proposal isn't a
+// draft anymore.
+func (m ProposalModel) Submit(id, translator int64, text, image string) (*Proposal, error) {
+	return m.update(id, func(ctx context.Context, tx DBTX, p *Proposal) error {
+		if p.Status != ProposalDraft {
+			return ErrProposalSubmitted
+		}
+		p.Status, p.Text, p.Image, p.ProposedBy = ProposalPending, text, image, &translator
+		_, err := tx.ExecContext(ctx, submitProposalQuery, id, text, image, translator)
+		return err
+	})
+}
+
+// update runs fn on the proposal locked in a transaction.
+func (m ProposalModel) update(id int64, fn func(ctx context.Context, tx DBTX, p *Proposal) error) (*Proposal, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := createContext()
+	defer cancel()
+
+	var p Proposal
+	err := withTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, lockProposalQuery, id).Scan(scanProposal(nil, &p)...)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		return fn(ctx, tx, &p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"encoding/json"
+	"greenlight.abdulalsh.com/internal/validator"
+	"time"
+)
+
+// The statuses of the publishing workflow of categories and items. Only the published
+// ones are shown to the readers who can't edit the catalogue.
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// Publishing is the workflow state shared by categories and items. A draft with a
+// PublishAt is published at that time, and a published entry with an UnpublishAt is
+// archived at that time.
+type Publishing struct {
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// ScheduleTime is a publish_at or unpublish_at sent by an editor. Given tells a missing
+// field, which leaves the schedule as it is, apart from an explicit null, which clears it.
+type ScheduleTime struct {
+	Given bool
+	Time  *time.Time
+}
+
+func (t *ScheduleTime) UnmarshalJSON(bytes []byte) error {
+	t.Given = true
+	return json.Unmarshal(bytes, &t.Time)
+}
+
+// Set applies the changes an editor asked for, where an empty status and the times that
+// aren't given are left as they are. Publishing or archiving by hand drops a pending
+// PublishAt, and archiving drops a pending UnpublishAt too.
+func (p *Publishing) Set(status string, publishAt, unpublishAt ScheduleTime) {
+	if status != "" {
+		p.Status = status
+		if status != StatusDraft {
+			p.PublishAt = nil
+		}
+		if status == StatusArchived {
+			p.UnpublishAt = nil
+		}
+	}
+	if publishAt.Given {
+		p.PublishAt = publishAt.Time
+	}
+	if unpublishAt.Given {
+		p.UnpublishAt = unpublishAt.Time
+	}
+}
+
+func ValidatePublishing(v *validator.Validator, p Publishing) {
+	v.Check(validator.PermittedValues(p.Status, StatusDraft, StatusPublished, StatusArchived), "status", "must be draft, published or archived")
+	v.Check(p.PublishAt == nil || p.Status == StatusDraft, "publish_at", "must only be set on a draft")
+	v.Check(p.UnpublishAt == nil || p.Status != StatusArchived, "unpublish_at", "must not be set on an archived entry")
+	if p.PublishAt != nil && p.UnpublishAt != nil {
+		v.Check(p.UnpublishAt.After(*p.PublishAt), "unpublish_at", "must be after publish_at")
+	}
+}
+
+const (
+	// categoryVisibleCondition is true for the category c when the readers may see it,
+	// which also covers the schedules that are due but haven't been applied by the
+	// sweeper yet. The queries skip it with a boolean parameter for the editors.
+	categoryVisibleCondition = `
+		((c.status = 'published' OR c.status = 'draft' AND c.publish_at <= now())
+			AND (c.unpublish_at IS NULL OR c.unpublish_at > now()))`
+
+	// itemVisibleCondition is the same for the item i.
+	itemVisibleCondition = `
+		((i.status = 'published' OR i.status = 'draft' AND i.publish_at <= now())
+			AND (i.unpublish_at IS NULL OR i.unpublish_at > now()))`
+
+	// the schedule that has run is cleared, the way it is when an editor does it by hand.
+	publishDueCategoriesQuery   = `UPDATE categories SET status = 'published', publish_at = NULL, version = version + 1 WHERE status = 'draft' AND publish_at <= now()`
+	unpublishDueCategoriesQuery = `UPDATE categories SET status = 'archived', unpublish_at = NULL, version = version + 1 WHERE status = 'published' AND unpublish_at <= now()`
+	publishDueItemsQuery        = `UPDATE items SET status = 'published', publish_at = NULL, version = version + 1 WHERE status = 'draft' AND publish_at <= now()`
+	unpublishDueItemsQuery      = `UPDATE items SET status = 'archived', unpublish_at = NULL, version = version + 1 WHERE status = 'published' AND unpublish_at <= now()`
+)
+
+// Published returns the models with the catalogue reads limited to the categories and
+// items the readers may see.
+func (m Models) Published() Models {
+	m.CategoryModel.publishedOnly = true
+	m.ItemModel.publishedOnly = true
+	return m
+}
+
+// PublishDue publishes the drafts whose publish_at has passed, and archives the
+// published categories whose unpublish_at has passed. It returns the number of
+// categories changed, for the sweeper.
+func (m CategoryModel) PublishDue() (int64, error) {
+	return runSchedule(m.DB, publishDueCategoriesQuery, unpublishDueCategoriesQuery)
+}
+
+// PublishDue does the same for the items.
+func (m ItemModel) PublishDue() (int64, error) {
+	return runSchedule(m.DB, publishDueItemsQuery, unpublishDueItemsQuery)
+}
+
+// runSchedule runs the publish query and then the unpublish one, so that an entry whose
+// whole schedule has passed ends up archived.
+func runSchedule(db DBTX, queries ...string) (int64, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	var changed int64
+	for _, query := range queries {
+		result, err := db.ExecContext(ctx, query)
+		if err != nil {
+			return changed, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return changed, err
+		}
+		changed += rowsAffected
+	}
+	return changed, nil
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// The slugs are set by the triggers of the translation tables from the translated title
+// or name, see migration 000026. A slug is unique within its language, and the slugs a
+// translation had before it was renamed are kept in the history tables. Unless $3 is
+// set, the slugs of the categories and items hidden from the readers aren't resolved, so
+// that a redirect doesn't give away their current slug.
+const (
+	resolveCategorySlugQuery = `
+		SELECT id, slug FROM (
+			SELECT t.category_id AS id, t.slug, 0 AS rank
+			FROM category_translations t
+			JOIN languages l ON t.language_id = l.id
+			WHERE l.code = $1 AND t.slug = $2
+			UNION ALL
+			SELECT t.category_id, t.slug, 1
+			FROM category_slug_history h
+			JOIN languages l ON h.language_id = l.id
+			JOIN category_translations t ON t.category_id = h.category_id AND t.language_id = h.language_id
+			WHERE l.code = $1 AND h.slug = $2
+		) s
+		JOIN categories c ON c.id = s.id
+		WHERE $3 OR ` + categoryVisibleCondition + `
+		ORDER BY rank
+		LIMIT 1`
+
+	resolveItemSlugQuery = `
+		SELECT id, slug FROM (
+			SELECT t.item_id AS id, t.slug, 0 AS rank
+			FROM item_translations t
+			JOIN languages l ON t.language_id = l.id
+			WHERE l.code = $1 AND t.slug = $2
+			UNION ALL
+			SELECT t.item_id, t.slug, 1
+			FROM item_slug_history h
+			JOIN languages l ON h.language_id = l.id
+			JOIN item_translations t ON t.item_id = h.item_id AND t.language_id = h.language_id
+			WHERE l.code = $1 AND h.slug = $2
+		) s
+		JOIN items i ON i.id = s.id
+		JOIN categories c ON c.id = i.category_id
+		WHERE $3 OR ` + itemVisibleCondition + ` AND ` + categoryVisibleCondition + `
+		ORDER BY rank
+		LIMIT 1`
+)
+
+// ResolveSlug returns the id of the category with the slug in the language lang, and its
+// current slug, which differs from slug when the category has been renamed since.
+func (m CategoryModel) ResolveSlug(lang, slug string) (int64, string, error) {
+	return resolveSlug(m.DB, resolveCategorySlugQuery, lang, slug, !m.publishedOnly)
+}
+
+// ResolveSlug returns the id of the item with the slug in the language lang, and its
+// current slug, in the same way as for categories.
+func (m ItemModel) ResolveSlug(lang, slug string) (int64, string, error) {
+	return resolveSlug(m.DB, resolveItemSlugQuery, lang, slug, !m.publishedOnly)
+}
+
+func resolveSlug(db DBTX, query, lang, slug string, all bool) (int64, string, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	var id int64
+	var current string
+	err := db.QueryRowContext(ctx, query, lang, slug, all).Scan(&id, &current)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, "", ErrRecordNotFound
+		default:
+			return 0, "", err
+		}
+	}
+	return id, current, nil
+}
@@ -0,0 +1,254 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"greenlight.abdulalsh.com/internal/validator"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var TagSlugRX = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
+var (
+	ErrDuplicateTag   = errors.New("duplicate tag")
+	ErrTagDoesntExist = errors.New("tag does not exist")
+)
+
+// Tag is a label such as "vegan" or "spicy" that can be put on any number of items,
+// across categories. The slug identifies the tag in the item filters, the name is
+// translated in the same way as the category titles.
+type Tag struct {
+	ID        int64     `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	Language  string    `json:"language"` // the language that was actually served
+	Fallback  bool      `json:"fallback"` // true when Language isn't the requested language
+	CreatedAt time.Time `json:"-"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateTagSlug(v *validator.Validator, key, slug string) {
+	v.Check(slug != "", key, "must be provided")
+	v.Check(len(slug) <= 50, key, "must not be more than 50 bytes long")
+	v.Check(validator.Matches(slug, TagSlugRX), key, "must be lowercase letters and digits separated by dashes")
+}
+
+func ValidateTag(v *validator.Validator, tag *Tag) {
+	ValidateTagSlug(v, "slug", tag.Slug)
+	v.Check(tag.Name != "", "name", "must be provided")
+	v.Check(len(tag.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(tag.Language != "", "language", "must be provided")
+	ValidateLanguage(v, tag.Language)
+}
+
+// ValidateItemTags checks the tag slugs given for an item.
+func ValidateItemTags(v *validator.Validator, tags []string) {
+	v.Check(len(tags) <= 20, "tags", "must not contain more than 20 tags")
+	v.Check(validator.Unique(tags), "tags", "must not contain duplicate values")
+	for _, slug := range tags {
+		v.Check(validator.Matches(slug, TagSlugRX), "tags", slug+" is not a valid tag")
+	}
+}
+
+type TagModel struct {
+	DB DBTX
+}
+
+const (
+	// tagTranslationLateral picks the translation of tag t in the first language of the
+	// chain $1 which has one, in the same way as categoryTranslationLateral. It is joined
+	// as tt.
+	tagTranslationLateral = `
+		LATERAL (
+			SELECT
+				l.code,
+				x.translation
+			FROM
+				tag_translations x
+			JOIN
+				languages l ON x.language_id = l.id
+			WHERE
+				x.tag_id = t.id AND l.code = ANY($1::text[])
+			ORDER BY
+				array_position($1::text[], l.code)
+			LIMIT 1
+		) tt ON true`
+
+	getTagsQuery = `
+		SELECT
+			t.id, t.slug, t.created_at, t.version, tt.code, tt.translation
+		FROM
+			tags t
+		JOIN ` + tagTranslationLateral + `
+		ORDER BY
+			t.slug`
+
+	getTagQuery = `
+		SELECT
+			t.id, t.slug, t.created_at, t.version, tt.code, tt.translation
+		FROM
+			tags t
+		JOIN ` + tagTranslationLateral + `
+		WHERE
+			t.id = $2`
+
+	insertTagQuery = `INSERT INTO tags (slug) VALUES ($1) RETURNING id, created_at, version`
+
+	upsertTagTranslationQuery = `
+		INSERT INTO tag_translations (tag_id, language_id, translation)
+		VALUES ($1, (SELECT id FROM languages WHERE code = $2), $3)
+		ON CONFLICT (tag_id, language_id)
+		DO UPDATE SET translation = EXCLUDED.translation`
+
+	// the slug is saved along with the version bump, an unchanged slug is a no-op.
+	updateTagQuery = `UPDATE tags SET slug = $1, version = version + 1 WHERE id = $2 AND version = $3 RETURNING version`
+
+	deleteTagQuery = `DELETE FROM tags WHERE id = $1`
+
+	// itemTagsColumn is the sorted slugs of the tags of item i.
+	itemTagsColumn = `ARRAY(SELECT t.slug FROM item_tags x JOIN tags t ON t.id = x.tag_id WHERE x.item_id = i.id ORDER BY t.slug)`
+
+	deleteItemTagsQuery = `DELETE FROM item_tags WHERE item_id = $1`
+
+	insertItemTagsQuery = `INSERT INTO item_tags (item_id, tag_id) SELECT $1, id FROM tags WHERE slug = ANY($2)`
+)
+
+// GetAll returns every tag translated along the languages chain, sorted by slug.
+func (m TagModel) GetAll(languages []string) ([]*Tag, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, getTagsQuery, pq.Array(languages))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.Slug, &t.CreatedAt, &t.Version, &t.Language, &t.Name); err != nil {
+			return nil, err
+		}
+		t.Fallback = isFallback(t.Language, languages)
+		tags = append(tags, &t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// Get returns the tag translated into the first of the languages that it has a
+// translation for.
+func (m TagModel) Get(id int64, languages ...string) (*Tag, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := createContext()
+	defer cancel()
+
+	var t Tag
+	err := m.DB.QueryRowContext(ctx, getTagQuery, pq.Array(languages), id).Scan(&t.ID, &t.Slug, &t.CreatedAt, &t.Version, &t.Language, &t.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	t.Fallback = isFallback(t.Language, languages)
+	return &t, nil
+}
+
+// Insert adds the tag along with its first translation.
+func (m TagModel) Insert(tag *Tag) error {
+	ctx, cancel := createContext()
+	defer cancel()
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, insertTagQuery, tag.Slug).Scan(&tag.ID, &tag.CreatedAt, &tag.Version)
+		if err != nil {
+			return tagSlugError(err)
+		}
+		_, err = tx.ExecContext(ctx, upsertTagTranslationQuery, tag.ID, tag.Language, tag.Name)
+		return err
+	})
+}
+
+// Update saves the slug of the tag and upserts its translation. It fails with
+// ErrEditConflict if the tag has been updated since it was read.
+func (m TagModel) Update(tag *Tag) error {
+	ctx, cancel := createContext()
+	defer cancel()
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, updateTagQuery, tag.Slug, tag.ID, tag.Version).Scan(&tag.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return tagSlugError(err)
+			}
+		}
+		_, err = tx.ExecContext(ctx, upsertTagTranslationQuery, tag.ID, tag.Language, tag.Name)
+		return err
+	})
+}
+
+// Delete removes a tag, which also takes it off the items.
+func (m TagModel) Delete(id int64) error {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, deleteTagQuery, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func tagSlugError(err error) error {
+	if strings.Contains(err.Error(), "tags_slug_key") {
+		return ErrDuplicateTag
+	}
+	return err
+}
+
+// setItemTags replaces the tags of an item with the tags of the given slugs, failing with
+// ErrTagDoesntExist if any of them isn't a tag.
+func setItemTags(ctx context.Context, tx DBTX, itemID int64, slugs []string) error {
+	_, err := tx.ExecContext(ctx, deleteItemTagsQuery, itemID)
+	if err != nil {
+		return err
+	}
+	if len(slugs) == 0 {
+		return nil
+	}
+	result, err := tx.ExecContext(ctx, insertItemTagsQuery, itemID, pq.Array(slugs))
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if int(rowsAffected) != len(slugs) {
+		return ErrTagDoesntExist
+	}
+	return nil
+}
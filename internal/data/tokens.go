@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/base32"
 	"fmt"
 	"greenlight.abdulalsh.com/internal/validator"
@@ -93,7 +92,7 @@ func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string, scope
 
 // Define the TokenModel type.
 type TokenModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 // The New() method is a shortcut which creates a new Token struct and then inserts the
@@ -144,6 +143,23 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	return err
 }
 
+// DeleteExpired() deletes every token whose expiry time has passed and returns the
+// number of tokens that were removed.
+func (m TokenModel) DeleteExpired() (int64, error) {
+	query := `
+        DELETE FROM tokens
+        WHERE expiry < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (m TokenModel) GetAllForUser(userId int64) ([]*Token, error) {
 
 	stmt := "SELECT hash,expiry,scope,user_id FROM tokens WHERE user_id = $1"
@@ -0,0 +1,427 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"greenlight.abdulalsh.com/internal/validator"
+	"math"
+	"time"
+)
+
+// The kinds of entity that hold translations.
+const (
+	EntityCategory = "category"
+	EntityItem     = "item"
+)
+
+// Coverage is the number of entities of one kind and how many of them are translated.
+type Coverage struct {
+	Total      int     `json:"total"`
+	Translated int     `json:"translated"`
+	Percent    float64 `json:"percent"`
+}
+
+// LanguageCoverage is the translation progress of one language.
+type LanguageCoverage struct {
+	Language   string   `json:"language"`
+	Name       string   `json:"name"`
+	Categories Coverage `json:"categories"`
+	Items      Coverage `json:"items"`
+}
+
+// Untranslated is a category or item that has no translation in a language, along with
+// its source (English) text for the translators to work from.
+type Untranslated struct {
+	Type        string    `json:"type"`
+	ID          int64     `json:"id"`
+	CategoryID  *int64    `json:"category_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	SourceText  string    `json:"source_text"`
+	SourceImage string    `json:"source_image,omitempty"`
+}
+
+// TranslationUnit is the source (English) text of a category title or item name along
+// with its translation in a target language, if it has one. It's what the translation
+// files are exported from and imported into.
+type TranslationUnit struct {
+	Type        string
+	ID          int64
+	Source      string
+	SourceImage string
+	Target      *string
+	TargetImage *string
+}
+
+// ValidateTranslationUnit checks the lang target of u with the same rules as the category
+// and item updates.
+func ValidateTranslationUnit(v *validator.Validator, lang string, u *TranslationUnit) {
+	text := ""
+	if u.Target != nil {
+		text = *u.Target
+	}
+	image := u.SourceImage
+	if u.TargetImage != nil {
+		image = *u.TargetImage
+	}
+	if u.Type == EntityItem {
+		ValidateItem(v, &Item{Name: text, Language: lang, Image: image})
+	} else {
+		ValidateCategory(v, &Category{Title: text, Language: lang, Image: image})
+	}
+}
+
+func ValidateEntityType(v *validator.Validator, entityType string) {
+	v.Check(entityType == "" || validator.PermittedValues(entityType, EntityCategory, EntityItem), "type", "must be category or item")
+}
+
+type TranslationModel struct {
+	DB DBTX
+}
+
+const (
+	getCoverageQuery = `
+		SELECT
+			l.code,
+			l.name,
+			(SELECT count(*) FROM categories),
+			(SELECT count(DISTINCT category_id) FROM category_translations WHERE language_id = l.id),
+			(SELECT count(*) FROM items),
+			(SELECT count(DISTINCT item_id) FROM item_translations WHERE language_id = l.id)
+		FROM
+			languages l
+		WHERE
+			l.enabled
+		ORDER BY
+			l.code;`
+
+	// the untranslated categories and items are listed together, $1 being the language
+	// they're missing, $2 the source language and $3 an optional entity type. When $6 is
+	// set the ones with a draft or pending proposal in $1 are left out.
+	getUntranslatedQuery = `
+		SELECT
+			count(*) OVER(), type, id, category_id, created_at, source_text, source_image
+		FROM (
+			SELECT
+				'category' AS type,
+				c.id,
+				NULL::bigint AS category_id,
+				c.created_at,
+				COALESCE(t.translation, '') AS source_text,
+				COALESCE(t.image, '') AS source_image
+			FROM
+				categories c
+			LEFT JOIN
+				category_translations t ON t.category_id = c.id AND t.language_id = (SELECT id FROM languages WHERE code = $2)
+			WHERE NOT EXISTS (
+				SELECT 1 FROM category_translations x JOIN languages l ON x.language_id = l.id
+				WHERE x.category_id = c.id AND l.code = $1
+			)
+			AND NOT ($6 AND EXISTS (
+				SELECT 1 FROM translation_proposals p JOIN languages l ON p.language_id = l.id
+				WHERE p.category_id = c.id AND l.code = $1 AND p.status IN ('draft', 'pending')
+			))
+			UNION ALL
+			SELECT
+				'item',
+				i.id,
+				i.category_id,
+				i.created_at,
+				COALESCE(t.translation, ''),
+				COALESCE(t.image, '')
+			FROM
+				items i
+			LEFT JOIN
+				item_translations t ON t.item_id = i.id AND t.language_id = (SELECT id FROM languages WHERE code = $2)
+			WHERE NOT EXISTS (
+				SELECT 1 FROM item_translations x JOIN languages l ON x.language_id = l.id
+				WHERE x.item_id = i.id AND l.code = $1
+			)
+			AND NOT ($6 AND EXISTS (
+				SELECT 1 FROM translation_proposals p JOIN languages l ON p.language_id = l.id
+				WHERE p.item_id = i.id AND l.code = $1 AND p.status IN ('draft', 'pending')
+			))
+		) u
+		WHERE
+			($3 = '' OR type = $3)
+		ORDER BY
+			%s %s, type ASC, id ASC
+		LIMIT $4 OFFSET $5;`
+
+	// every category and item with its source text ($2) and its translation in $1.
+	getTranslationUnitsQuery = `
+		SELECT
+			'category', c.id, COALESCE(s.translation, ''), COALESCE(s.image, ''), t.translation, t.image
+		FROM
+			categories c
+		LEFT JOIN
+			category_translations s ON s.category_id = c.id AND s.language_id = (SELECT id FROM languages WHERE code = $2)
+		LEFT JOIN
+			category_translations t ON t.category_id = c.id AND t.language_id = (SELECT id FROM languages WHERE code = $1)
+		UNION ALL
+		SELECT
+			'item', i.id, COALESCE(s.translation, ''), COALESCE(s.image, ''), t.translation, t.image
+		FROM
+			items i
+		LEFT JOIN
+			item_translations s ON s.item_id = i.id AND s.language_id = (SELECT id FROM languages WHERE code = $2)
+		LEFT JOIN
+			item_translations t ON t.item_id = i.id AND t.language_id = (SELECT id FROM languages WHERE code = $1)
+		ORDER BY
+			1, 2;`
+
+	// the imports upsert the $1 translations of every unit of a kind in one statement,
+	// $2 holding the ids and $3 and $4 the texts and images, and then bump the versions.
+	importCategoryTranslationsQuery = `
+		INSERT INTO category_translations (category_id, language_id, translation, image)
+		SELECT u.id, (SELECT id FROM languages WHERE code = $1), u.translation, u.image
+		FROM unnest($2::bigint[], $3::text[], $4::text[]) AS u(id, translation, image)
+		ON CONFLICT (category_id, language_id)
+		DO UPDATE SET translation = EXCLUDED.translation, image = EXCLUDED.image`
+
+	importItemTranslationsQuery = `
+		INSERT INTO item_translations (item_id, language_id, translation, image)
+		SELECT u.id, (SELECT id FROM languages WHERE code = $1), u.translation, u.image
+		FROM unnest($2::bigint[], $3::text[], $4::text[]) AS u(id, translation, image)
+		ON CONFLICT (item_id, language_id)
+		DO UPDATE SET translation = EXCLUDED.translation, image = EXCLUDED.image`
+
+	bumpCategoryVersionsQuery = `UPDATE categories SET version = version + 1 WHERE id = ANY($1)`
+	bumpItemVersionsQuery     = `UPDATE items SET version = version + 1 WHERE id = ANY($1)`
+)
+
+// translationDeleteQueries are the queries deleteTranslation runs for one kind of entity.
+type translationDeleteQueries struct {
+	lock, count, delete, bump string
+}
+
+// deleteTranslation removes the lang translation of an entity and returns its image.
+func deleteTranslation(db DBTX, q translationDeleteQueries, id int64, lang string) (string, error) {
+	if lang == SourceLanguage {
+		return "", ErrSourceTranslation
+	}
+
+	ctx, cancel := createContext()
+	defer cancel()
+	var image string
+	err := withTx(ctx, db, func(tx DBTX) error {
+		var locked int64
+		err := tx.QueryRowContext(ctx, q.lock, id).Scan(&locked)
+		if err != nil {
+			return err
+		}
+		var translations int
+		err = tx.QueryRowContext(ctx, q.count, id).Scan(&translations)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, q.delete, id, lang).Scan(&image)
+		if err != nil {
+			return err
+		}
+		if translations <= 1 {
+			return ErrLastTranslation
+		}
+		_, err = tx.ExecContext(ctx, q.bump, id)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrRecordNotFound
+	}
+	return image, err
+}
+
+// percentOf returns translated as a percentage of total, rounded to two decimals. With
+// nothing to translate a language is fully covered.
+func percentOf(translated, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(translated)*10000/float64(total)) / 100
+}
+
+// Coverage returns the translation progress of every enabled language.
+func (m TranslationModel) Coverage() ([]*LanguageCoverage, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, getCoverageQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coverage := []*LanguageCoverage{}
+	for rows.Next() {
+		var c LanguageCoverage
+		err := rows.Scan(
+			&c.Language,
+			&c.Name,
+			&c.Categories.Total,
+			&c.Categories.Translated,
+			&c.Items.Total,
+			&c.Items.Translated,
+		)
+		if err != nil {
+			return nil, err
+		}
+		c.Categories.Percent = percentOf(c.Categories.Translated, c.Categories.Total)
+		c.Items.Percent = percentOf(c.Items.Translated, c.Items.Total)
+		coverage = append(coverage, &c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return coverage, nil
+}
+
+// Untranslated returns a page of the categories and items that have no translation in
+// lang. entityType restricts the list to one kind of entity when it isn't empty.
+func (m TranslationModel) Untranslated(lang string, entityType string, filters Filters) ([]*Untranslated, Metadata, error) {
+	return m.untranslated(lang, entityType, false, filters)
+}
+
+// Unproposed is like Untranslated but also leaves out the categories and items which
+// already have a draft or pending proposal in lang, so that they aren't suggested twice.
+func (m TranslationModel) Unproposed(lang string, entityType string, filters Filters) ([]*Untranslated, Metadata, error) {
+	return m.untranslated(lang, entityType, true, filters)
+}
+
+func (m TranslationModel) untranslated(lang string, entityType string, skipProposed bool, filters Filters) ([]*Untranslated, Metadata, error) {
+	query := fmt.Sprintf(getUntranslatedQuery, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := createContext()
+	defer cancel()
+
+	args := []any{lang, SourceLanguage, entityType, filters.limit(), filters.offset(), skipProposed}
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	untranslated := []*Untranslated{}
+	for rows.Next() {
+		var u Untranslated
+		err := rows.Scan(&totalRecords, &u.Type, &u.ID, &u.CategoryID, &u.CreatedAt, &u.SourceText, &u.SourceImage)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		untranslated = append(untranslated, &u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return untranslated, metadata, nil
+}
+
+// Units returns the translation units of every category and item for lang.
+func (m TranslationModel) Units(lang string) ([]*TranslationUnit, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, getTranslationUnitsQuery, lang, SourceLanguage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := []*TranslationUnit{}
+	for rows.Next() {
+		var u TranslationUnit
+		err := rows.Scan(&u.Type, &u.ID, &u.Source, &u.SourceImage, &u.Target, &u.TargetImage)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, &u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return units, nil
+}
+
+// Import saves the targets of units as the lang translations, with one upsert for the
+// categories and one for the items so that a large file fits within the timeout, all in
+// one transaction. A new translation gets the image of the source translation. The
+// versions are bumped so that an edit started before the import conflicts rather than
+// overwriting it.
+func (m TranslationModel) Import(lang string, units []*TranslationUnit) error {
+	type batch struct {
+		ids           []int64
+		index         map[int64]int
+		texts, images []string
+		upsert, bump  string
+	}
+	batches := []*batch{
+		{index: map[int64]int{}, upsert: importCategoryTranslationsQuery, bump: bumpCategoryVersionsQuery},
+		{index: map[int64]int{}, upsert: importItemTranslationsQuery, bump: bumpItemVersionsQuery},
+	}
+	for _, u := range units {
+		if u.Target == nil {
+			continue
+		}
+		image := u.SourceImage
+		if u.TargetImage != nil {
+			image = *u.TargetImage
+		}
+		b := batches[0]
+		if u.Type == EntityItem {
+			b = batches[1]
+		}
+		// a unit given twice keeps its last target, as an upsert can't change a row twice.
+		if i, ok := b.index[u.ID]; ok {
+			b.texts[i], b.images[i] = *u.Target, image
+			continue
+		}
+		b.index[u.ID] = len(b.ids)
+		b.ids = append(b.ids, u.ID)
+		b.texts = append(b.texts, *u.Target)
+		b.images = append(b.images, image)
+	}
+
+	ctx, cancel := createContext()
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx DBTX) error {
+		for _, b := range batches {
+			if len(b.ids) == 0 {
+				continue
+			}
+			_, err := tx.ExecContext(ctx, b.upsert, lang, pq.Array(b.ids), pq.Array(b.texts), pq.Array(b.images))
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, b.bump, pq.Array(b.ids))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// upsertTranslation saves a category or item translation through the same upsert as the
+// updates, and bumps the version of the entity.
+func upsertTranslation(ctx context.Context, tx DBTX, entityType string, id int64, lang, text, image string) error {
+	query, bump := updateCategoryTranslationQuert, bumpCategoryVersionAnyQuery
+	if entityType == EntityItem {
+		query, bump = upsertItemTranslationQuery, bumpItemVersionAnyQuery
+	}
+	_, err := tx.ExecContext(ctx, query, id, lang, text, image)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, bump, id)
+	return err
+}
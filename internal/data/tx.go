@@ -0,0 +1,50 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DBTX is what the models run their statements on. It's satisfied by both *sql.DB and
+// *sql.Tx, so the same model can work on its own or as part of a wider transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// withTx runs fn in a transaction, committing it when fn succeeds and rolling it back
+// otherwise. When db is already a transaction, fn joins it and the commit is left to
+// whoever started it.
+func withTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	conn, ok := db.(interface {
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	})
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Atomic runs fn with a copy of the models bound to a single transaction, so that the
+// operations fn makes on them, over any number of models, are committed together or
+// not at all.
+func (m Models) Atomic(fn func(tx Models) error) error {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	return withTx(ctx, m.db, func(tx DBTX) error {
+		return fn(newModels(tx))
+	})
+}
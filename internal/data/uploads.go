@@ -0,0 +1,110 @@
+package data
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+// Upload is an image uploaded through POST /v1/uploads, along with its thumbnail. The
+// URL (or the thumbnail URL) is what categories and items reference as their image.
+type Upload struct {
+	ID           int64     `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UserID       *int64    `json:"-"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+}
+
+type UploadModel struct {
+	DB DBTX
+}
+
+const (
+	insertUploadQuery = `
+		INSERT INTO uploads (user_id, url, thumbnail_url, storage_key, thumbnail_key, content_type, size, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`
+
+	// an upload is orphaned once neither its URL nor its thumbnail URL is the image of
+	// a category or item translation, or of a draft or pending proposal.
+	unreferencedUploadCondition = `
+		NOT EXISTS (SELECT 1 FROM category_translations t WHERE t.image IN (u.url, u.thumbnail_url))
+		AND NOT EXISTS (SELECT 1 FROM item_translations t WHERE t.image IN (u.url, u.thumbnail_url))
+		AND NOT EXISTS (SELECT 1 FROM translation_proposals p WHERE p.status IN ('draft', 'pending') AND p.image IN (u.url, u.thumbnail_url))`
+
+	deleteUnreferencedUploadsQuery = `
+		DELETE FROM uploads u
+		WHERE (u.url = ANY($1) OR u.thumbnail_url = ANY($1)) AND ` + unreferencedUploadCondition + `
+		RETURNING u.storage_key, u.thumbnail_key`
+
+	deleteAbandonedUploadsQuery = `
+		DELETE FROM uploads u
+		WHERE u.created_at < $1 AND ` + unreferencedUploadCondition + `
+		RETURNING u.storage_key, u.thumbnail_key`
+)
+
+func (m UploadModel) Insert(upload *Upload) error {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	args := []any{
+		upload.UserID,
+		upload.URL,
+		upload.ThumbnailURL,
+		upload.StorageKey,
+		upload.ThumbnailKey,
+		upload.ContentType,
+		upload.Size,
+		upload.Width,
+		upload.Height,
+	}
+	return m.DB.QueryRowContext(ctx, insertUploadQuery, args...).Scan(&upload.ID, &upload.CreatedAt)
+}
+
+// DeleteUnreferenced removes the uploads behind the given image URLs that nothing
+// references anymore, and returns the storage keys of their files so that the caller
+// can remove them. It is used once a category or item has been deleted.
+func (m UploadModel) DeleteUnreferenced(urls []string) ([]string, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	return m.deleteReturningKeys(deleteUnreferencedUploadsQuery, pq.Array(urls))
+}
+
+// DeleteAbandoned removes the uploads older than maxAge that nothing references, such
+// as images that were replaced or never used, and returns the storage keys of their files.
+func (m UploadModel) DeleteAbandoned(maxAge time.Duration) ([]string, error) {
+	return m.deleteReturningKeys(deleteAbandonedUploadsQuery, time.Now().Add(-maxAge))
+}
+
+func (m UploadModel) deleteReturningKeys(query string, args ...any) ([]string, error) {
+	ctx, cancel := createContext()
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key, thumbnailKey string
+		if err := rows.Scan(&key, &thumbnailKey); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key, thumbnailKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
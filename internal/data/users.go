@@ -108,7 +108,7 @@ func ValidateUser(v *validator.Validator, user *User) {
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 // Insert a new record in the database for the user. Note that the id, created_at and
@@ -218,6 +218,34 @@ func (m UserModel) Update(user *User) error {
 	return nil
 }
 
+// DeleteUnactivated() removes accounts which were never activated and were created more
+// than maxAge ago. Users who still hold a live activation token are left alone so that
+// they can finish signing up. It returns the number of users that were removed.
+func (m UserModel) DeleteUnactivated(maxAge time.Duration) (int64, error) {
+	query := `
+        DELETE FROM users
+        WHERE activated = false
+        AND created_at < $1
+        AND NOT EXISTS (
+            SELECT 1 FROM tokens
+            WHERE tokens.user_id = users.id
+            AND tokens.scope = $2
+            AND tokens.expiry > $3
+        )`
+
+	now := time.Now()
+	args := []any{now.Add(-maxAge), ScopeActivation, now}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
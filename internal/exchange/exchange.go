@@ -0,0 +1,75 @@
+// Package exchange reads and writes the file formats translation tools work with, so
+// that strings can be sent to translators and their work imported back.
+package exchange
+
+import (
+	"errors"
+	"io"
+)
+
+// The supported file formats.
+const (
+	FormatXLIFF = "xliff"
+	FormatPO    = "po"
+)
+
+var ErrUnknownFormat = errors.New("unknown translation file format")
+
+// Unit is a single translatable string. ID identifies what the string belongs to and is
+// carried through the tools untouched, Target is empty until it has been translated.
+type Unit struct {
+	ID     string
+	Source string
+	Target string
+}
+
+// Document is a set of units translated from one language into another.
+type Document struct {
+	SourceLanguage string
+	TargetLanguage string
+	Units          []Unit
+}
+
+// ContentType returns the media type of a format.
+func ContentType(format string) string {
+	switch format {
+	case FormatXLIFF:
+		return "application/xliff+xml"
+	case FormatPO:
+		return "text/x-gettext-translation"
+	}
+	return ""
+}
+
+// Extension returns the usual file extension of a format.
+func Extension(format string) string {
+	switch format {
+	case FormatXLIFF:
+		return ".xlf"
+	case FormatPO:
+		return ".po"
+	}
+	return ""
+}
+
+// Write encodes doc to w in the given format.
+func Write(w io.Writer, format string, doc Document) error {
+	switch format {
+	case FormatXLIFF:
+		return WriteXLIFF(w, doc)
+	case FormatPO:
+		return WritePO(w, doc)
+	}
+	return ErrUnknownFormat
+}
+
+// Read decodes a document in the given format from r.
+func Read(r io.Reader, format string) (Document, error) {
+	switch format {
+	case FormatXLIFF:
+		return ReadXLIFF(r)
+	case FormatPO:
+		return ReadPO(r)
+	}
+	return Document{}, ErrUnknownFormat
+}
@@ -0,0 +1,151 @@
+package exchange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WritePO encodes doc as a gettext PO file. The unit ID goes in msgctxt, since the same
+// source string can belong to several categories or items.
+func WritePO(w io.Writer, doc Document) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, `msgid ""`)
+	fmt.Fprintln(bw, `msgstr ""`)
+	fmt.Fprintln(bw, poQuote("Content-Type: text/plain; charset=UTF-8\n"))
+	fmt.Fprintln(bw, poQuote("Content-Transfer-Encoding: 8bit\n"))
+	fmt.Fprintln(bw, poQuote("X-Source-Language: "+doc.SourceLanguage+"\n"))
+	fmt.Fprintln(bw, poQuote("Language: "+doc.TargetLanguage+"\n"))
+
+	for _, u := range doc.Units {
+		fmt.Fprintln(bw)
+		fmt.Fprintf(bw, "#: %s\n", u.ID)
+		fmt.Fprintf(bw, "msgctxt %s\n", poQuote(u.ID))
+		fmt.Fprintf(bw, "msgid %s\n", poQuote(u.Source))
+		fmt.Fprintf(bw, "msgstr %s\n", poQuote(u.Target))
+	}
+
+	return bw.Flush()
+}
+
+// poQuote quotes s as a PO string. The escapes PO uses are a subset of Go's, but
+// non-ASCII text is kept as is rather than escaped.
+func poQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// poUnquote reverses poQuote.
+func poUnquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("invalid PO string %s", s)
+	}
+	return strconv.Unquote(s)
+}
+
+// ReadPO decodes a gettext PO file. Obsolete entries (#~) and plural forms are not
+// produced by WritePO and are ignored.
+func ReadPO(r io.Reader) (Document, error) {
+	var doc Document
+	var entry map[string]*strings.Builder
+	var field string
+
+	flush := func() {
+		if entry == nil {
+			return
+		}
+		get := func(key string) string {
+			if b, ok := entry[key]; ok {
+				return b.String()
+			}
+			return ""
+		}
+		if _, ok := entry["msgid"]; ok {
+			if get("msgid") == "" && get("msgctxt") == "" {
+				doc.readPOHeader(get("msgstr"))
+			} else {
+				id := get("msgctxt")
+				if id == "" {
+					id = get("msgid")
+				}
+				doc.Units = append(doc.Units, Unit{ID: id, Source: get("msgid"), Target: get("msgstr")})
+			}
+		}
+		entry, field = nil, ""
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#"):
+			// comments only ever precede an entry.
+			if field != "" {
+				flush()
+			}
+		case strings.HasPrefix(line, `"`):
+			if field == "" {
+				return Document{}, fmt.Errorf("invalid PO: unexpected string on line %d", n)
+			}
+			s, err := poUnquote(line)
+			if err != nil {
+				return Document{}, fmt.Errorf("invalid PO: line %d: %w", n, err)
+			}
+			entry[field].WriteString(s)
+		default:
+			keyword, value, ok := strings.Cut(line, " ")
+			if !ok {
+				return Document{}, fmt.Errorf("invalid PO: line %d", n)
+			}
+			switch keyword {
+			case "msgctxt", "msgid", "msgstr":
+			default:
+				return Document{}, fmt.Errorf("invalid PO: unsupported keyword %s on line %d", keyword, n)
+			}
+			// a msgctxt or msgid after a msgstr starts the next entry.
+			if keyword != "msgstr" && entry != nil && entry["msgstr"] != nil {
+				flush()
+			}
+			if entry == nil {
+				entry = make(map[string]*strings.Builder)
+			}
+			s, err := poUnquote(strings.TrimSpace(value))
+			if err != nil {
+				return Document{}, fmt.Errorf("invalid PO: line %d: %w", n, err)
+			}
+			entry[keyword] = &strings.Builder{}
+			entry[keyword].WriteString(s)
+			field = keyword
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Document{}, err
+	}
+	flush()
+
+	return doc, nil
+}
+
+// readPOHeader picks the languages out of the header entry.
+func (doc *Document) readPOHeader(header string) {
+	for _, line := range strings.Split(header, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case "Language":
+			doc.TargetLanguage = strings.TrimSpace(value)
+		case "X-Source-Language":
+			doc.SourceLanguage = strings.TrimSpace(value)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package exchange
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+const xliffNamespace = "urn:oasis:names:tc:xliff:document:1.2"
+
+// The XLIFF 1.2 elements we use. The tags carry no namespace so that documents are read
+// whatever namespace (if any) the tool that produced them declared.
+type xliffDocument struct {
+	XMLName xml.Name    `xml:"xliff"`
+	Xmlns   string      `xml:"xmlns,attr,omitempty"`
+	Version string      `xml:"version,attr"`
+	Files   []xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	Original       string      `xml:"original,attr"`
+	SourceLanguage string      `xml:"source-language,attr"`
+	TargetLanguage string      `xml:"target-language,attr,omitempty"`
+	Datatype       string      `xml:"datatype,attr"`
+	Units          []xliffUnit `xml:"body>trans-unit"`
+}
+
+type xliffUnit struct {
+	ID     string       `xml:"id,attr"`
+	Source string       `xml:"source"`
+	Target *xliffTarget `xml:"target,omitempty"`
+}
+
+type xliffTarget struct {
+	State string `xml:"state,attr,omitempty"`
+	Text  string `xml:",chardata"`
+}
+
+// WriteXLIFF encodes doc as an XLIFF 1.2 file. Untranslated units get an empty target
+// in the "new" state, which is what most tools expect.
+func WriteXLIFF(w io.Writer, doc Document) error {
+	file := xliffFile{
+		Original:       "catalog",
+		SourceLanguage: doc.SourceLanguage,
+		TargetLanguage: doc.TargetLanguage,
+		Datatype:       "plaintext",
+	}
+	for _, u := range doc.Units {
+		target := &xliffTarget{State: "translated", Text: u.Target}
+		if u.Target == "" {
+			target.State = "new"
+		}
+		file.Units = append(file.Units, xliffUnit{ID: u.ID, Source: u.Source, Target: target})
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(xliffDocument{Xmlns: xliffNamespace, Version: "1.2", Files: []xliffFile{file}})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// ReadXLIFF decodes an XLIFF 1.2 file. The units of every <file> element are returned
+// together, and the languages are taken from the first one.
+func ReadXLIFF(r io.Reader) (Document, error) {
+	var x xliffDocument
+	err := xml.NewDecoder(r).Decode(&x)
+	if err != nil {
+		return Document{}, fmt.Errorf("invalid XLIFF: %w", err)
+	}
+	if len(x.Files) == 0 {
+		return Document{}, fmt.Errorf("invalid XLIFF: no file element")
+	}
+
+	doc := Document{
+		SourceLanguage: x.Files[0].SourceLanguage,
+		TargetLanguage: x.Files[0].TargetLanguage,
+	}
+	for _, file := range x.Files {
+		for _, u := range file.Units {
+			unit := Unit{ID: u.ID, Source: u.Source}
+			if u.Target != nil {
+				unit.Target = u.Target.Text
+			}
+			doc.Units = append(doc.Units, unit)
+		}
+	}
+	return doc, nil
+}
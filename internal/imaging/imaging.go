@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// The image formats we accept, these are the ones the standard library can decode.
+const (
+	JPEG = "image/jpeg"
+	PNG  = "image/png"
+	GIF  = "image/gif"
+)
+
+// Extension returns the file extension for one of the supported content types.
+func Extension(contentType string) string {
+	switch contentType {
+	case JPEG:
+		return ".jpg"
+	case PNG:
+		return ".png"
+	case GIF:
+		return ".gif"
+	}
+	return ""
+}
+
+// Thumbnail scales img down so that it fits in a maxSize x maxSize square, keeping its
+// aspect ratio. Every pixel of the thumbnail is the average of the source pixels it
+// covers (a box filter), which is good enough for thumbnails and only needs the
+// standard library. Images that already fit are returned unchanged.
+func Thumbnail(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxSize && srcH <= maxSize {
+		return img
+	}
+
+	dstW, dstH := maxSize, maxSize
+	if srcW > srcH {
+		dstH = max(1, srcH*maxSize/srcW)
+	} else {
+		dstW = max(1, srcW*maxSize/srcH)
+	}
+
+	// the source columns covered by each column of the thumbnail.
+	cols := make([]int, dstW+1)
+	for x := 0; x <= dstW; x++ {
+		cols[x] = x * srcW / dstW
+	}
+
+	// The source is converted into RGBA a row at a time, through the fast paths
+	// image/draw has for the decoded types such as *image.YCbCr, rather than reading
+	// every pixel through img.At.
+	row := image.NewRGBA(image.Rect(0, 0, srcW, 1))
+	sums := make([]uint64, dstW*4)
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := y * srcH / dstH
+		y1 := max(y0+1, (y+1)*srcH/dstH)
+		clear(sums)
+		for sy := y0; sy < y1; sy++ {
+			draw.Draw(row, row.Rect, img, image.Pt(bounds.Min.X, bounds.Min.Y+sy), draw.Src)
+			for x := 0; x < dstW; x++ {
+				x1 := max(cols[x]+1, cols[x+1])
+				s := sums[x*4 : x*4+4 : x*4+4]
+				p := row.Pix[cols[x]*4 : x1*4]
+				for i := 0; i < len(p); i += 4 {
+					s[0] += uint64(p[i])
+					s[1] += uint64(p[i+1])
+					s[2] += uint64(p[i+2])
+					s[3] += uint64(p[i+3])
+				}
+			}
+		}
+		out := dst.Pix[y*dst.Stride : y*dst.Stride+dstW*4]
+		for x := 0; x < dstW; x++ {
+			n := uint64((y1 - y0) * (max(cols[x]+1, cols[x+1]) - cols[x]))
+			for c := 0; c < 4; c++ {
+				out[x*4+c] = uint8(sums[x*4+c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// Encode writes img to w in the given format. GIFs are written as PNGs, since a
+// thumbnail only keeps the first frame and a PNG holds its colors better.
+func Encode(w io.Writer, img image.Image, contentType string) (string, error) {
+	switch contentType {
+	case JPEG:
+		return JPEG, jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	case PNG, GIF:
+		return PNG, png.Encode(w, img)
+	}
+	return "", ErrUnsupportedFormat
+}
@@ -0,0 +1,78 @@
+package mt
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// Dictionary is a deterministic provider backed by a glossary, which is handy in
+// development and as a stand-in when no translation service is configured. A text found
+// whole in the glossary gets its entry, otherwise the words are looked up one by one and
+// the ones the glossary doesn't know are kept as they are. A text without any known word
+// gets no suggestion.
+type Dictionary struct {
+	// entries maps the target language to the lowercased source phrases and words.
+	entries map[string]map[string]string
+}
+
+// NewDictionary returns a Dictionary with the given glossary, keyed by target language
+// and then by source phrase. The source phrases are matched case insensitively.
+func NewDictionary(entries map[string]map[string]string) *Dictionary {
+	d := &Dictionary{entries: make(map[string]map[string]string, len(entries))}
+	for target, glossary := range entries {
+		d.entries[target] = make(map[string]string, len(glossary))
+		for source, translation := range glossary {
+			d.entries[target][strings.ToLower(strings.TrimSpace(source))] = translation
+		}
+	}
+	return d
+}
+
+// LoadDictionary reads the glossary from a JSON file such as
+//
+//	{"ar": {"hot drinks": "مشروبات ساخنة", "coffee": "قهوة"}}
+func LoadDictionary(path string) (*Dictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries map[string]map[string]string
+	err = json.NewDecoder(f).Decode(&entries)
+	if err != nil {
+		return nil, err
+	}
+	return NewDictionary(entries), nil
+}
+
+func (d *Dictionary) Name() string {
+	return ProviderDictionary
+}
+
+// Translate looks the texts up in the glossary of target, the source language is the one
+// the glossary was written from.
+func (d *Dictionary) Translate(ctx context.Context, source, target string, texts []string) ([]string, error) {
+	glossary := d.entries[target]
+	translations := make([]string, len(texts))
+	for i, text := range texts {
+		if t, ok := glossary[strings.ToLower(strings.TrimSpace(text))]; ok {
+			translations[i] = t
+			continue
+		}
+		words := strings.Fields(text)
+		known := false
+		for j, word := range words {
+			if t, ok := glossary[strings.ToLower(word)]; ok {
+				words[j] = t
+				known = true
+			}
+		}
+		if known {
+			translations[i] = strings.Join(words, " ")
+		}
+	}
+	return translations, nil
+}
@@ -0,0 +1,75 @@
+package mt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Doer sends an HTTP request, *http.Client is one.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// HTTP calls a translation service over JSON. It POSTs
+//
+//	{"source": "en", "target": "ar", "texts": ["Hot drinks"]}
+//
+// to the url and expects {"translations": ["مشروبات ساخنة"]} back, in the same order
+// as the texts. The service can be a thin adapter in front of a commercial API or a
+// local stand-in.
+type HTTP struct {
+	url    string
+	apiKey string
+	client Doer
+}
+
+// NewHTTP returns an HTTP provider for the service at url. The apiKey, if any, is sent
+// as a bearer token.
+func NewHTTP(url, apiKey string, client Doer) *HTTP {
+	return &HTTP{url: url, apiKey: apiKey, client: client}
+}
+
+func (p *HTTP) Name() string {
+	return ProviderHTTP
+}
+
+func (p *HTTP) Translate(ctx context.Context, source, target string, texts []string) ([]string, error) {
+	body, err := json.Marshal(map[string]any{"source": source, "target": target, "texts": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("machine translation service responded %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	var out struct {
+		Translations []string `json:"translations"`
+	}
+	err = json.NewDecoder(io.LimitReader(res.Body, 10<<20)).Decode(&out)
+	if err != nil {
+		return nil, fmt.Errorf("machine translation service: %w", err)
+	}
+	if len(out.Translations) != len(texts) {
+		return nil, fmt.Errorf("machine translation service returned %d translations for %d texts", len(out.Translations), len(texts))
+	}
+	return out.Translations, nil
+}
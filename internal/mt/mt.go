@@ -0,0 +1,45 @@
+// Package mt machine translates the catalogue strings, to give the translators a first
+// draft to work from. The drafts are only ever saved as proposals, see the suggest
+// endpoint, so a reviewer decides what gets published.
+package mt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// The names of the providers, as given to the mt-provider flag.
+const (
+	ProviderDictionary = "dictionary"
+	ProviderHTTP       = "http"
+)
+
+var ErrUnknownProvider = errors.New("unknown machine translation provider")
+
+// Provider translates texts from the source language into the target language. The
+// translations come back in the same order as texts, exactly one for each text, an empty
+// string meaning that the provider has no translation to suggest for that text.
+type Provider interface {
+	Name() string
+	Translate(ctx context.Context, source, target string, texts []string) ([]string, error)
+}
+
+// New returns the provider called name. The dictionary provider reads its glossary from
+// dictionaryPath, if given, and the HTTP one calls url.
+func New(name, dictionaryPath, url, apiKey string, client Doer) (Provider, error) {
+	switch name {
+	case ProviderDictionary:
+		if dictionaryPath == "" {
+			return NewDictionary(nil), nil
+		}
+		return LoadDictionary(dictionaryPath)
+	case ProviderHTTP:
+		if url == "" {
+			return nil, fmt.Errorf("the %s machine translation provider needs a url", name)
+		}
+		return NewHTTP(url, apiKey, client), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+}
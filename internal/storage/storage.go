@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage is where uploaded files are kept. Put saves the content under key and returns
+// the public URL of the file, and Delete removes it again. The local filesystem driver
+// is the only one for now, but an object store (S3, GCS...) only needs to implement
+// these two methods to be used instead.
+type Storage interface {
+	Put(key string, r io.Reader) (string, error)
+	Delete(key string) error
+}
+
+// Local stores the files in a directory on the local filesystem. The files are served
+// by Handler() under baseURL.
+type Local struct {
+	root    string
+	baseURL string
+}
+
+// NewLocal returns a Local driver storing files in root, creating the directory if it
+// doesn't exist yet.
+func NewLocal(root, baseURL string) (*Local, error) {
+	err := os.MkdirAll(root, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &Local{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// path returns the location of key on disk. Keys are flat file names, anything that
+// could escape the root directory is rejected.
+func (l *Local) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.root, key), nil
+}
+
+func (l *Local) Put(key string, r io.Reader) (string, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first and rename it once it's complete, so that a
+	// failed upload never leaves a truncated file behind under the final name.
+	tmp, err := os.CreateTemp(l.root, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return "", err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return "", err
+	}
+	err = os.Chmod(tmp.Name(), 0o644)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", err
+	}
+
+	return l.baseURL + "/" + key, nil
+}
+
+// Delete removes the file stored under key. A file that is already gone isn't an error.
+func (l *Local) Delete(key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Handler serves the stored files. Directory listings are disabled so that the uploads
+// can't be enumerated.
+func (l *Local) Handler() http.Handler {
+	fs := http.FileServer(http.Dir(l.root))
+	return http.StripPrefix(l.baseURL, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") || strings.HasPrefix(filepath.Base(r.URL.Path), ".") {
+			http.NotFound(w, r)
+			return
+		}
+		fs.ServeHTTP(w, r)
+	}))
+}
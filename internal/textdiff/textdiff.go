@@ -0,0 +1,78 @@
+// Package textdiff compares two versions of a short text, such as a translation and a
+// proposed change to it, word by word.
+package textdiff
+
+import (
+	"regexp"
+)
+
+// The kinds of change in a diff.
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Op is a run of text that is kept, inserted or deleted going from the old text to the
+// new one.
+type Op struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// tokenRX splits a text into words and the whitespace between them, so that joining
+// the tokens gives back the text.
+var tokenRX = regexp.MustCompile(`\s+|\S+`)
+
+// Words returns the changes that turn a into b. The words are matched with a longest
+// common subsequence, and adjacent tokens with the same Op are merged.
+func Words(a, b string) []Op {
+	x := tokenRX.FindAllString(a, -1)
+	y := tokenRX.FindAllString(b, -1)
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := []Op{}
+	add := func(op, text string) {
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, Op{Op: op, Text: text})
+	}
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			add(OpEqual, x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(OpDelete, x[i])
+			i++
+		default:
+			add(OpInsert, y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		add(OpDelete, x[i])
+	}
+	for ; j < len(y); j++ {
+		add(OpInsert, y[j])
+	}
+	return ops
+}
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS tokens_expiry_idx ON tokens (expiry);
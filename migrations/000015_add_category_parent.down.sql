@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS reparent_category_children_trigger ON categories;
+DROP FUNCTION IF EXISTS reparent_category_children;
+
+DROP TRIGGER IF EXISTS prevent_category_cycle_trigger ON categories;
+DROP FUNCTION IF EXISTS prevent_category_cycle;
+
+DROP INDEX IF EXISTS categories_parent_id_idx;
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_parent_check;
+ALTER TABLE categories DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,42 @@
+-- Categories can now be nested, a NULL parent_id means the category is at the top level.
+ALTER TABLE categories ADD COLUMN parent_id bigint REFERENCES categories(id);
+ALTER TABLE categories ADD CONSTRAINT categories_parent_check CHECK (parent_id <> id);
+CREATE INDEX IF NOT EXISTS categories_parent_id_idx ON categories (parent_id);
+
+-- Create a function to prevent a category from becoming its own ancestor
+CREATE OR REPLACE FUNCTION prevent_category_cycle()
+    RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.parent_id IS NOT NULL AND EXISTS (
+        WITH RECURSIVE ancestors AS (
+            SELECT id, parent_id FROM categories WHERE id = NEW.parent_id
+            UNION
+            SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+        )
+        SELECT 1 FROM ancestors WHERE id = NEW.id
+    ) THEN
+        RAISE EXCEPTION 'Category hierarchy cycle detected';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER prevent_category_cycle_trigger
+    BEFORE INSERT OR UPDATE OF parent_id ON categories
+    FOR EACH ROW
+EXECUTE FUNCTION prevent_category_cycle();
+
+-- Create a function to move the children of a deleted category up to its parent.
+-- Triggers fire in name order, so prevent_default_category_deletion_trigger still runs first.
+CREATE OR REPLACE FUNCTION reparent_category_children()
+    RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE categories SET parent_id = OLD.parent_id WHERE parent_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reparent_category_children_trigger
+    BEFORE DELETE ON categories
+    FOR EACH ROW
+EXECUTE FUNCTION reparent_category_children();
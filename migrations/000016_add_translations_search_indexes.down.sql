@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS category_translations_translation_idx;
+DROP INDEX IF EXISTS item_translations_translation_idx;
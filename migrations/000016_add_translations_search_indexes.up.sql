@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS category_translations_translation_idx ON category_translations USING GIN (to_tsvector('simple', translation));
+CREATE INDEX IF NOT EXISTS item_translations_translation_idx ON item_translations USING GIN (to_tsvector('simple', translation));
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS items_category_id_idx;
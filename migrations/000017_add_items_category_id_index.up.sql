@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS items_category_id_idx ON items (category_id);
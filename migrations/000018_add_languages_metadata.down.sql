@@ -0,0 +1,7 @@
+DELETE FROM permissions WHERE code = 'languages:write';
+
+ALTER TABLE languages DROP CONSTRAINT IF EXISTS languages_code_key;
+ALTER TABLE languages DROP COLUMN IF EXISTS version;
+ALTER TABLE languages DROP COLUMN IF EXISTS enabled;
+ALTER TABLE languages DROP CONSTRAINT IF EXISTS languages_direction_check;
+ALTER TABLE languages DROP COLUMN IF EXISTS direction;
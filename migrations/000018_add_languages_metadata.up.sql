@@ -0,0 +1,15 @@
+-- The languages table becomes the registry of supported languages, so it needs to carry
+-- the metadata the clients render with and a way to switch a language off.
+ALTER TABLE languages ADD COLUMN direction text NOT NULL DEFAULT 'ltr';
+ALTER TABLE languages ADD CONSTRAINT languages_direction_check CHECK (direction IN ('ltr', 'rtl'));
+ALTER TABLE languages ADD COLUMN enabled bool NOT NULL DEFAULT true;
+ALTER TABLE languages ADD COLUMN version integer NOT NULL DEFAULT 1;
+ALTER TABLE languages ADD CONSTRAINT languages_code_key UNIQUE (code);
+
+UPDATE languages SET direction = 'rtl' WHERE code = 'ar';
+
+-- The seed languages were inserted with explicit ids, move the sequence past them
+SELECT setval(pg_get_serial_sequence('languages', 'id'), (SELECT MAX(id) FROM languages));
+
+INSERT INTO permissions (code)
+VALUES ('languages:write');
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS item_translations_image_idx;
+DROP INDEX IF EXISTS category_translations_image_idx;
+DROP TABLE IF EXISTS uploads;
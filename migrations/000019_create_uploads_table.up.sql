@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS uploads (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    url text NOT NULL UNIQUE,
+    thumbnail_url text NOT NULL,
+    storage_key text NOT NULL,
+    thumbnail_key text NOT NULL,
+    content_type text NOT NULL,
+    size bigint NOT NULL,
+    width integer NOT NULL,
+    height integer NOT NULL
+);
+
+-- the orphaned uploads are found by looking up their URLs in the translations.
+CREATE INDEX IF NOT EXISTS category_translations_image_idx ON category_translations (image);
+CREATE INDEX IF NOT EXISTS item_translations_image_idx ON item_translations (image);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS items_attributes_idx;
+ALTER TABLE items DROP COLUMN IF EXISTS attributes;
+DROP TABLE IF EXISTS category_attribute_translations;
+DROP TABLE IF EXISTS category_attributes;
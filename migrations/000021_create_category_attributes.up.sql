@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS category_attributes (
+    id bigserial PRIMARY KEY,
+    category_id bigint NOT NULL REFERENCES categories ON DELETE CASCADE,
+    key text NOT NULL,
+    type text NOT NULL CHECK (type IN ('number', 'text', 'boolean', 'enum', 'money')),
+    required boolean NOT NULL DEFAULT false,
+    options text[] NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT category_attributes_category_id_key_key UNIQUE (category_id, key)
+);
+
+CREATE TABLE IF NOT EXISTS category_attribute_translations (
+    attribute_id bigint NOT NULL REFERENCES category_attributes ON DELETE CASCADE,
+    language_id integer NOT NULL REFERENCES languages ON DELETE CASCADE,
+    label text NOT NULL,
+    PRIMARY KEY (attribute_id, language_id)
+);
+
+ALTER TABLE items ADD COLUMN IF NOT EXISTS attributes jsonb NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS items_attributes_idx ON items USING GIN (attributes);
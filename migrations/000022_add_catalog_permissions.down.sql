@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code IN ('catalog:read', 'catalog:write', 'catalog:translate');
@@ -0,0 +1,10 @@
+INSERT INTO permissions (code)
+VALUES
+    ('catalog:read'),
+    ('catalog:write'),
+    ('catalog:translate');
+
+-- The catalogue was open to everyone until now, keep it readable for the existing users.
+INSERT INTO users_permissions
+SELECT users.id, permissions.id FROM users, permissions WHERE permissions.code = 'catalog:read'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS items_category_id_position_idx;
+DROP INDEX IF EXISTS categories_parent_id_position_idx;
+ALTER TABLE items DROP COLUMN IF EXISTS position;
+ALTER TABLE categories DROP COLUMN IF EXISTS position;
@@ -0,0 +1,15 @@
+-- The position orders a category among its siblings and an item within its category.
+-- The existing rows keep the order they were listed in, which was by id.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS position integer NOT NULL DEFAULT 0;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS position integer NOT NULL DEFAULT 0;
+
+UPDATE categories c SET position = o.position
+FROM (SELECT id, row_number() OVER (PARTITION BY parent_id ORDER BY id) AS position FROM categories) o
+WHERE c.id = o.id;
+
+UPDATE items i SET position = o.position
+FROM (SELECT id, row_number() OVER (PARTITION BY category_id ORDER BY id) AS position FROM items) o
+WHERE i.id = o.id;
+
+CREATE INDEX IF NOT EXISTS categories_parent_id_position_idx ON categories (parent_id, position);
+CREATE INDEX IF NOT EXISTS items_category_id_position_idx ON items (category_id, position);
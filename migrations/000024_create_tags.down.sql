@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS item_tags;
+DROP TABLE IF EXISTS tag_translations;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id bigserial PRIMARY KEY,
+    slug text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT tags_slug_key UNIQUE (slug)
+);
+
+CREATE TABLE IF NOT EXISTS tag_translations (
+    id serial PRIMARY KEY,
+    tag_id bigint NOT NULL REFERENCES tags ON DELETE CASCADE,
+    language_id integer NOT NULL REFERENCES languages ON DELETE CASCADE,
+    translation text NOT NULL,
+    CONSTRAINT tag_translations_tag_id_language_id_key UNIQUE (tag_id, language_id)
+);
+
+CREATE TABLE IF NOT EXISTS item_tags (
+    item_id bigint NOT NULL REFERENCES items ON DELETE CASCADE,
+    tag_id bigint NOT NULL REFERENCES tags ON DELETE CASCADE,
+    PRIMARY KEY (item_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS item_tags_tag_id_idx ON item_tags (tag_id);
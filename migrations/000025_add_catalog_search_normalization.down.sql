@@ -0,0 +1,15 @@
+DROP COLLATION IF EXISTS catalog_ar;
+DROP COLLATION IF EXISTS catalog_en;
+DROP COLLATION IF EXISTS catalog_root;
+
+DROP INDEX IF EXISTS item_translations_search_text_idx;
+DROP INDEX IF EXISTS category_translations_search_text_idx;
+CREATE INDEX IF NOT EXISTS category_translations_translation_idx ON category_translations USING GIN (to_tsvector('simple', translation));
+CREATE INDEX IF NOT EXISTS item_translations_translation_idx ON item_translations USING GIN (to_tsvector('simple', translation));
+
+DROP TRIGGER IF EXISTS item_translations_search_text ON item_translations;
+DROP TRIGGER IF EXISTS category_translations_search_text ON category_translations;
+DROP FUNCTION IF EXISTS set_translation_search_text();
+ALTER TABLE item_translations DROP COLUMN IF EXISTS search_text;
+ALTER TABLE category_translations DROP COLUMN IF EXISTS search_text;
+DROP FUNCTION IF EXISTS catalog_normalize(text, text);
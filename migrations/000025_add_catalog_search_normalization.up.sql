@@ -0,0 +1,52 @@
+-- catalog_normalize folds a translation, or a search term, for matching. Everything is
+-- lowercased, and Arabic also loses its diacritics (tashkeel, U+064B-U+065F and U+0670)
+-- and tatweel (U+0640). The alef forms أ إ آ ٱ become ا, the hamza carriers ؤ ئ become
+-- و ي, alef maqsura ى becomes ي and ta marbuta ة becomes ه.
+CREATE OR REPLACE FUNCTION catalog_normalize(lang text, s text) RETURNS text
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT CASE lang
+        WHEN 'ar' THEN translate(regexp_replace(lower(s), '[\u064B-\u065F\u0670\u0640]', '', 'g'), 'أإآٱؤئىة', 'ااااوييه')
+        ELSE lower(s)
+    END
+$$;
+
+-- search_text is the normalised translation, kept up to date by a trigger since it
+-- depends on the code of the language.
+ALTER TABLE category_translations ADD COLUMN IF NOT EXISTS search_text text NOT NULL DEFAULT '';
+ALTER TABLE item_translations ADD COLUMN IF NOT EXISTS search_text text NOT NULL DEFAULT '';
+
+CREATE OR REPLACE FUNCTION set_translation_search_text() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.search_text := catalog_normalize((SELECT code FROM languages WHERE id = NEW.language_id), NEW.translation);
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER category_translations_search_text
+BEFORE INSERT OR UPDATE OF translation, language_id ON category_translations
+FOR EACH ROW EXECUTE FUNCTION set_translation_search_text();
+
+CREATE TRIGGER item_translations_search_text
+BEFORE INSERT OR UPDATE OF translation, language_id ON item_translations
+FOR EACH ROW EXECUTE FUNCTION set_translation_search_text();
+
+UPDATE category_translations t SET search_text = catalog_normalize(l.code, t.translation)
+FROM languages l WHERE l.id = t.language_id;
+
+UPDATE item_translations t SET search_text = catalog_normalize(l.code, t.translation)
+FROM languages l WHERE l.id = t.language_id;
+
+-- the search now runs on search_text.
+DROP INDEX IF EXISTS category_translations_translation_idx;
+DROP INDEX IF EXISTS item_translations_translation_idx;
+CREATE INDEX IF NOT EXISTS category_translations_search_text_idx ON category_translations USING GIN (to_tsvector('simple', search_text));
+CREATE INDEX IF NOT EXISTS item_translations_search_text_idx ON item_translations USING GIN (to_tsvector('simple', search_text));
+
+-- The ICU collations the listings sort the translations with, see collations in
+-- internal/data. The root collation is used for the languages that don't have their own.
+-- They aren't indexed, as the listings sort the translation picked along the language
+-- chain of each row, which mixes languages.
+CREATE COLLATION IF NOT EXISTS catalog_root (provider = icu, locale = 'und');
+CREATE COLLATION IF NOT EXISTS catalog_en (provider = icu, locale = 'en');
+CREATE COLLATION IF NOT EXISTS catalog_ar (provider = icu, locale = 'ar');
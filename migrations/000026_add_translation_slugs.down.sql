@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS item_translations_slug_idx;
+DROP INDEX IF EXISTS category_translations_slug_idx;
+DROP TRIGGER IF EXISTS item_translations_slug ON item_translations;
+DROP TRIGGER IF EXISTS category_translations_slug ON category_translations;
+DROP FUNCTION IF EXISTS set_item_translation_slug();
+DROP FUNCTION IF EXISTS set_category_translation_slug();
+DROP TABLE IF EXISTS item_slug_history;
+DROP TABLE IF EXISTS category_slug_history;
+ALTER TABLE item_translations DROP COLUMN IF EXISTS slug;
+ALTER TABLE category_translations DROP COLUMN IF EXISTS slug;
+DROP FUNCTION IF EXISTS catalog_slugify(text);
@@ -0,0 +1,117 @@
+-- catalog_slugify turns a translation into the words of a URL: lowercased, without the
+-- Arabic diacritics and tatweel, and with every run of other characters than letters and
+-- digits replaced by a single dash. The regular expression runs under the ICU root
+-- collation so that [:alnum:] covers the letters of every script whatever the locale of
+-- the database is.
+CREATE OR REPLACE FUNCTION catalog_slugify(s text) RETURNS text
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT left(trim(BOTH '-' FROM regexp_replace(
+        regexp_replace(lower(s), '[\u064B-\u065F\u0670\u0640]', '', 'g') COLLATE catalog_root,
+        '[^[:alnum:]]+', '-', 'g')), 100)
+$$;
+
+ALTER TABLE category_translations ADD COLUMN IF NOT EXISTS slug text;
+ALTER TABLE item_translations ADD COLUMN IF NOT EXISTS slug text;
+
+-- The slugs a translation had before it was renamed, so that the old URLs can redirect
+-- to the current slug.
+CREATE TABLE IF NOT EXISTS category_slug_history (
+    language_id integer NOT NULL REFERENCES languages(id) ON DELETE CASCADE,
+    slug        text NOT NULL,
+    category_id bigint NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    created_at  timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (language_id, slug)
+);
+
+CREATE TABLE IF NOT EXISTS item_slug_history (
+    language_id integer NOT NULL REFERENCES languages(id) ON DELETE CASCADE,
+    slug        text NOT NULL,
+    item_id     bigint NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    created_at  timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (language_id, slug)
+);
+
+-- The slug is set from the translation when it's added or renamed. A slug taken by
+-- another category in the same language gets the first free -2, -3... suffix, and a
+-- translation with no letters or digits at all falls back to the id.
+CREATE OR REPLACE FUNCTION set_category_translation_slug() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    base text;
+    candidate text;
+    n integer := 1;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.slug IS NOT NULL AND NEW.translation IS NOT DISTINCT FROM OLD.translation THEN
+        RETURN NEW;
+    END IF;
+    base := coalesce(nullif(catalog_slugify(NEW.translation), ''), NEW.category_id::text);
+    candidate := base;
+    WHILE EXISTS (SELECT 1 FROM category_translations
+                  WHERE language_id = NEW.language_id AND slug = candidate AND category_id <> NEW.category_id) LOOP
+        n := n + 1;
+        candidate := base || '-' || n;
+    END LOOP;
+    IF TG_OP = 'UPDATE' AND OLD.slug IS NOT NULL AND OLD.slug <> candidate THEN
+        INSERT INTO category_slug_history (language_id, slug, category_id)
+        VALUES (OLD.language_id, OLD.slug, OLD.category_id)
+        ON CONFLICT (language_id, slug) DO UPDATE SET category_id = EXCLUDED.category_id, created_at = NOW();
+    END IF;
+    NEW.slug := candidate;
+    RETURN NEW;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION set_item_translation_slug() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    base text;
+    candidate text;
+    n integer := 1;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.slug IS NOT NULL AND NEW.translation IS NOT DISTINCT FROM OLD.translation THEN
+        RETURN NEW;
+    END IF;
+    base := coalesce(nullif(catalog_slugify(NEW.translation), ''), NEW.item_id::text);
+    candidate := base;
+    WHILE EXISTS (SELECT 1 FROM item_translations
+                  WHERE language_id = NEW.language_id AND slug = candidate AND item_id <> NEW.item_id) LOOP
+        n := n + 1;
+        candidate := base || '-' || n;
+    END LOOP;
+    IF TG_OP = 'UPDATE' AND OLD.slug IS NOT NULL AND OLD.slug <> candidate THEN
+        INSERT INTO item_slug_history (language_id, slug, item_id)
+        VALUES (OLD.language_id, OLD.slug, OLD.item_id)
+        ON CONFLICT (language_id, slug) DO UPDATE SET item_id = EXCLUDED.item_id, created_at = NOW();
+    END IF;
+    NEW.slug := candidate;
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER category_translations_slug
+BEFORE INSERT OR UPDATE ON category_translations
+FOR EACH ROW EXECUTE FUNCTION set_category_translation_slug();
+
+CREATE TRIGGER item_translations_slug
+BEFORE INSERT OR UPDATE ON item_translations
+FOR EACH ROW EXECUTE FUNCTION set_item_translation_slug();
+
+-- the existing translations get their slugs one by one in id order, so the oldest keeps
+-- the unsuffixed slug.
+DO $$
+DECLARE
+    r record;
+BEGIN
+    FOR r IN SELECT id FROM category_translations ORDER BY id LOOP
+        UPDATE category_translations SET slug = NULL WHERE id = r.id;
+    END LOOP;
+    FOR r IN SELECT id FROM item_translations ORDER BY id LOOP
+        UPDATE item_translations SET slug = NULL WHERE id = r.id;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE category_translations ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE item_translations ALTER COLUMN slug SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS category_translations_slug_idx ON category_translations (language_id, slug);
+CREATE UNIQUE INDEX IF NOT EXISTS item_translations_slug_idx ON item_translations (language_id, slug);
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS items_unpublish_at_idx;
+DROP INDEX IF EXISTS items_publish_at_idx;
+DROP INDEX IF EXISTS categories_unpublish_at_idx;
+DROP INDEX IF EXISTS categories_publish_at_idx;
+
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_schedule_check;
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_status_check;
+ALTER TABLE items DROP COLUMN IF EXISTS unpublish_at;
+ALTER TABLE items DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE items DROP COLUMN IF EXISTS status;
+
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_schedule_check;
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_status_check;
+ALTER TABLE categories DROP COLUMN IF EXISTS unpublish_at;
+ALTER TABLE categories DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE categories DROP COLUMN IF EXISTS status;
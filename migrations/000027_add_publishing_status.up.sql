@@ -0,0 +1,19 @@
+-- The existing categories and items were live already, so they start out published.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published';
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS unpublish_at timestamp(0) with time zone;
+ALTER TABLE categories ADD CONSTRAINT categories_status_check CHECK (status IN ('draft', 'published', 'archived'));
+ALTER TABLE categories ADD CONSTRAINT categories_schedule_check CHECK (unpublish_at > publish_at);
+
+ALTER TABLE items ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published';
+ALTER TABLE items ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS unpublish_at timestamp(0) with time zone;
+ALTER TABLE items ADD CONSTRAINT items_status_check CHECK (status IN ('draft', 'published', 'archived'));
+ALTER TABLE items ADD CONSTRAINT items_schedule_check CHECK (unpublish_at > publish_at);
+
+-- the scheduler looks for the drafts due to be published and the published rows due to
+-- be archived.
+CREATE INDEX IF NOT EXISTS categories_publish_at_idx ON categories (publish_at) WHERE status = 'draft';
+CREATE INDEX IF NOT EXISTS categories_unpublish_at_idx ON categories (unpublish_at) WHERE status = 'published';
+CREATE INDEX IF NOT EXISTS items_publish_at_idx ON items (publish_at) WHERE status = 'draft';
+CREATE INDEX IF NOT EXISTS items_unpublish_at_idx ON items (unpublish_at) WHERE status = 'published';
@@ -0,0 +1,22 @@
+-- A proposal is a translation change made by a translator, which is only applied to
+-- the category or item translation once a reviewer approves it.
+CREATE TABLE IF NOT EXISTS translation_proposals (
+    id bigserial PRIMARY KEY,
+    category_id bigint REFERENCES categories ON DELETE CASCADE,
+    item_id bigint REFERENCES items ON DELETE CASCADE,
+    language_id integer NOT NULL REFERENCES languages ON DELETE CASCADE,
+    translation text NOT NULL,
+    image text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'pending',
+    proposed_by bigint REFERENCES users ON DELETE SET NULL,
+    reviewed_by bigint REFERENCES users ON DELETE SET NULL,
+    note text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    reviewed_at timestamp(0) with time zone,
+    CONSTRAINT translation_proposals_entity_check CHECK ((category_id IS NULL) <> (item_id IS NULL)),
+    CONSTRAINT translation_proposals_status_check CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS translation_proposals_pending_idx ON translation_proposals (created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS translation_proposals_category_id_idx ON translation_proposals (category_id);
+CREATE INDEX IF NOT EXISTS translation_proposals_item_id_idx ON translation_proposals (item_id);
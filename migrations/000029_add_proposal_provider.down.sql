@@ -0,0 +1 @@
+ALTER TABLE translation_proposals DROP COLUMN IF EXISTS provider;
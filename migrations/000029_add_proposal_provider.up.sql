@@ -0,0 +1,3 @@
+-- provider is the machine translation provider a proposal was suggested by, it's empty
+-- for the proposals written by the translators themselves.
+ALTER TABLE translation_proposals ADD COLUMN IF NOT EXISTS provider text NOT NULL DEFAULT '';
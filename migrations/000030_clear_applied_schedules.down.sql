@@ -0,0 +1 @@
+-- The cleared schedules had already run, there is nothing to restore.
@@ -0,0 +1,6 @@
+-- The scheduler used to leave publish_at and unpublish_at behind once it had applied them,
+-- which the publishing validation then refused on every later update.
+UPDATE categories SET publish_at = NULL WHERE status <> 'draft' AND publish_at IS NOT NULL;
+UPDATE categories SET unpublish_at = NULL WHERE status = 'archived' AND unpublish_at IS NOT NULL;
+UPDATE items SET publish_at = NULL WHERE status <> 'draft' AND publish_at IS NOT NULL;
+UPDATE items SET unpublish_at = NULL WHERE status = 'archived' AND unpublish_at IS NOT NULL;
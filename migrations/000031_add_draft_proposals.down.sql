@@ -0,0 +1,3 @@
+DELETE FROM translation_proposals WHERE status = 'draft';
+ALTER TABLE translation_proposals DROP CONSTRAINT IF EXISTS translation_proposals_status_check;
+ALTER TABLE translation_proposals ADD CONSTRAINT translation_proposals_status_check CHECK (status IN ('pending', 'approved', 'rejected'));
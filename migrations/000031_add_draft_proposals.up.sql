@@ -0,0 +1,4 @@
+-- A draft is a machine translation suggestion, which a translator edits and submits
+-- before it's reviewed.
+ALTER TABLE translation_proposals DROP CONSTRAINT IF EXISTS translation_proposals_status_check;
+ALTER TABLE translation_proposals ADD CONSTRAINT translation_proposals_status_check CHECK (status IN ('draft', 'pending', 'approved', 'rejected'));
@@ -0,0 +1,9 @@
+-- The translation a proposal was made against, NULL when there was none. A proposal
+-- can't be approved once the translation has changed from it.
+ALTER TABLE translation_proposals ADD COLUMN IF NOT EXISTS base_translation text;
+
+UPDATE translation_proposals p
+SET base_translation = COALESCE(
+    (SELECT translation FROM category_translations WHERE category_id = p.category_id AND language_id = p.language_id),
+    (SELECT translation FROM item_translations WHERE item_id = p.item_id AND language_id = p.language_id))
+WHERE p.status IN ('draft', 'pending');